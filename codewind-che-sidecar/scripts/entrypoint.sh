@@ -30,9 +30,17 @@ if [ -z $CWServiceName ]; then
     echo "ERROR: The Codewind service was not found"
 fi
 
-echo "Codewind is now ready."
+# Wait for Codewind to become available before pointing the proxy and filewatcherd at it. Exit rather than starting
+# them against a Codewind that isn't ready, so that the failure shows in the sidecar status
+if deploy-pfe wait --timeout "${CODEWIND_WAIT_TIMEOUT:-10m}"; then
+    echo "Codewind is now ready."
+else
+    echo "ERROR: Codewind did not become ready, see the errors above"
+    exit 1
+fi
 echo "Setting proxy to Codewind service: $CWServiceName"
-CWServiceNameEndpoint=https://$CWServiceName:9191
+# Codewind serves plain HTTP rather than HTTPS when TLS is terminated in front of it, so take the URL from the service
+CWServiceNameEndpoint=$(deploy-pfe get-service --endpoint)
 
 _____FROM_DOMAIN_NAME="${_____FROM_DOMAIN_NAME:-localhost}"
 _____TO_DOMAIN_NAME="${_____TO_DOMAIN_NAME:-$CWServiceNameEndpoint}"
@@ -1,130 +1,663 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"deploy-pfe/pkg/che"
 	"deploy-pfe/pkg/codewind"
+	"deploy-pfe/pkg/config"
 	"deploy-pfe/pkg/constants"
 	"deploy-pfe/pkg/kube"
 
 	routev1 "github.com/openshift/client-go/route/clientset/versioned/typed/route/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 func main() {
+	// Parse the command and its flags, and load the settings from the config file, the environment and the flags
+	// before anything else, so that invalid settings are reported before anything is deployed
+	command, args := "deploy", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	flags := flag.NewFlagSet("deploy-pfe "+command, flag.ExitOnError)
+	configLoader := config.AddFlags(flags)
+
+	// Rendering and printing the config never touch the cluster, so they're handled before connecting to Kubernetes
+	switch command {
+	case "render":
+		render(flags, configLoader, args)
+		return
+	case "config":
+		printConfig(flags, configLoader, args)
+		return
+	}
+
+	var noRollback, keepPVC, endpoint *bool
+	var waitTimeout *time.Duration
+	var teardownNamespace *string
+	switch command {
+	case "deploy":
+		// Objects created by a failed deployment are deleted again, unless they're kept to debug the failure
+		noRollback = flags.Bool("no-rollback", false, "keep the objects created by a failed deployment, to debug the failure")
+	case "wait":
+		waitTimeout = flags.Duration("timeout", constants.WaitTimeout, "how long to wait for Codewind to become available")
+	case "teardown":
+		teardownNamespace = flags.String("namespace", "", "namespace that Codewind is deployed in (default the current namespace)")
+		keepPVC = flags.Bool("keep-pvc", false, "keep the persistent volume claim holding the Codewind workspace")
+	case "get-service":
+		endpoint = flags.Bool("endpoint", false, "print the URL that Codewind serves on, rather than the name of its service")
+	case "preflight":
+	default:
+		log.Errorf("Unknown command %q, expected render, config, get-service, wait, teardown or preflight\n", command)
+		os.Exit(1)
+	}
+	flags.Parse(args)
+	settings := loadConfig(configLoader)
+
 	// Get the Kube config and clientsets
-	config, err := rest.InClusterConfig()
+	kubeConfig, err := rest.InClusterConfig()
 	if err != nil {
 		// Couldn't find an InClusterConfig, may be running outside of Kube, so try to find a local kube config file
 		kubeconfig := filepath.Join(os.Getenv("HOME"), ".kube", "config")
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		kubeConfig, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
 		if err != nil {
 			log.Errorf("Unable to retrieve Kubernetes InClusterConfig %v\n", err)
 			os.Exit(1)
 		}
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
 	if err != nil {
 		log.Errorf("Unable to retrieve Kubernetes clientset %v\n", err)
 		os.Exit(1)
 	}
 
-	// Get the current namespace
-	namespace := kube.GetCurrentNamespace()
+	// Ingresses are managed through the dynamic client, as the ingress API version depends on the cluster
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		log.Errorf("Unable to retrieve Kubernetes dynamic client %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get the current namespace, unless teardown was given the namespace to remove Codewind from
+	namespace := ""
+	if command == "teardown" {
+		namespace = *teardownNamespace
+	}
+	if namespace == "" {
+		namespace, err = kube.GetCurrentNamespace()
+		if err != nil {
+			log.Errorf("Unable to determine the current namespace: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// If deploy-pfe was called with the `teardown` arg, remove the Codewind resources of the workspace, and exit.
+	// This is handled before looking up the Che workspace ID, as it can also be run by an admin from outside of the workspace
+	if command == "teardown" {
+		teardown(kubeConfig, clientset, dynamicClient, namespace, settings.Workspace.ID, *keepPVC)
+		return
+	}
 
 	// Get the Che workspace ID
-	cheWorkspaceID := os.Getenv("CHE_WORKSPACE_ID")
+	cheWorkspaceID := settings.Workspace.ID
 	if cheWorkspaceID == "" {
 		log.Errorln("Che Workspace ID not set and unable to deploy PFE, exiting...")
 		os.Exit(1)
 	}
 
-	// If deploy-pfe was called with the `get-service` arg, retrieve the codewind service name if it exists, and exit
-	if len(os.Args) > 1 {
-		if os.Args[1] == "get-service" {
+	// If deploy-pfe was called with the `get-service` arg, retrieve the codewind service name (or URL) if it exists, and exit
+	if command == "get-service" {
+		if *endpoint {
+			fmt.Println(che.GetPFEEndpoint(clientset, namespace, cheWorkspaceID))
+		} else {
 			fmt.Println(che.GetPFEService(clientset, namespace, cheWorkspaceID))
-			return
 		}
+		return
+	}
+	// If deploy-pfe was called with the `wait` arg, wait for Codewind to become available, and exit
+	if command == "wait" {
+		waitForCodewind(clientset, namespace, cheWorkspaceID, *waitTimeout)
+		return
+	}
+
+	// Check the Codewind settings before looking anything up in the cluster
+	err = validateSettings(settings)
+	if err != nil {
+		log.Errorf("Invalid Codewind settings: %v\n", err)
+		os.Exit(1)
 	}
 
 	// Get the ingress domain used for Che (and Che workspaces)
-	cheIngress, err := che.GetCheIngress(os.Getenv("CHE_API"))
+	cheIngress, err := che.GetCheIngress(settings.Workspace.CheAPI)
 	if err != nil {
 		log.Errorf("Unable to determine Che ingress domain: %v\n", err)
 		os.Exit(1)
 	}
 	log.Infof("Ingress: %s\n", cheIngress)
 
+	// Get the ownership strategy, and determine if we're running on OpenShift or not, which decide what is deployed
+	ownershipStrategy, err := codewind.GetOwnershipStrategy(settings)
+	if err != nil {
+		log.Errorf("Invalid Codewind settings: %v\n", err)
+		os.Exit(1)
+	}
+	onOpenShift, err := kube.DetectOpenShift(kubeConfig)
+	if err != nil {
+		log.Errorf("Unable to detect if running on OpenShift: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Create the Codewind deployment object. Its service account and owner are filled in once the permissions to
+	// look them up have been checked
+	codewindInstance, err := newCodewind(settings, cheWorkspaceID, namespace, cheIngress, "", metav1.OwnerReference{}, onOpenShift)
+	if err != nil {
+		log.Errorf("Invalid Codewind settings: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Use the newest ingress API version served by the cluster, whose API group the ingress permissions are checked in.
+	// The other exposure strategies only remove a previous ingress, so they don't need an ingress API version to be served
+	if codewindInstance.Exposure != codewind.ExposureRoute && codewindInstance.Exposure != codewind.ExposureGateway {
+		codewindInstance.IngressAPIVersion, err = kube.DetectIngressAPIVersion(clientset.Discovery())
+		if err != nil && codewindInstance.Exposure == codewind.ExposureIngress {
+			log.Errorf("Error: Unable to determine the ingress API version: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// If deploy-pfe was called with the `preflight` arg, only check the permissions that deploying needs, and exit.
+	// Otherwise they're checked before deploying, so that deploying doesn't fail halfway through
+	if command == "preflight" {
+		if !preflight(clientset, codewindInstance, ownershipStrategy, settings.ClusterRole) {
+			os.Exit(1)
+		}
+		return
+	}
+	if !preflight(clientset, codewindInstance, ownershipStrategy, settings.ClusterRole) {
+		log.Errorln("Missing permissions to deploy Codewind, exiting...")
+		os.Exit(1)
+	}
+
+	var rollback *codewind.Rollback
+	if !*noRollback {
+		rollback = codewind.NewRollback()
+	}
+	failDeploy := func() {
+		if rollback == nil {
+			log.Warnln("Rollback is disabled, keeping the objects created by this deployment")
+		} else if failed := rollback.Run(); failed > 0 {
+			log.Errorf("%d objects created by this deployment couldn't be rolled back, remove them with `deploy-pfe teardown`\n", failed)
+		}
+		log.Errorf("Codewind deployment failed, exiting...")
+		os.Exit(1)
+	}
+
 	// Get the Che workspace service account to use with Codewind
-	serviceAccountName := che.GetWorkspaceServiceAccount(clientset, namespace, cheWorkspaceID)
+	serviceAccountName, err := che.GetWorkspaceServiceAccount(clientset, namespace, cheWorkspaceID)
+	if err != nil {
+		log.Errorf("Unable to determine the Che workspace service account: %v\n", err)
+		os.Exit(1)
+	}
 	log.Infof("Service Account: %s\n", serviceAccountName)
+	codewindInstance.ServiceAccountName = serviceAccountName
 
-	// Get the Owner reference name and uid
-	ownerReferenceName, ownerReferenceUID := che.GetOwnerReferences(clientset, namespace, cheWorkspaceID)
+	// Get the owner that the Codewind resources will be tied to, depending on the ownership strategy
+	ownerReference, err := codewind.GetOwner(clientset, namespace, cheWorkspaceID, ownershipStrategy, rollback)
+	if err != nil {
+		switch e := err.(type) {
+		case *che.NoOwnerReferencesError:
+			log.Warnf("%v. Codewind will not be removed along with the workspace, use `deploy-pfe teardown` to remove it\n", e)
+		case *che.WorkspacePodNotFoundError:
+			if e.Err != nil {
+				log.Errorf("Unable to determine the owner of the Che workspace: %v\n", err)
+				os.Exit(1)
+			}
+			log.Warnf("%v. Codewind will not be removed along with the workspace, use `deploy-pfe teardown` to remove it\n", e)
+		default:
+			log.Errorf("Unable to determine the owner of the Che workspace: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		log.Infof("Owner: %s %s\n", ownerReference.Kind, ownerReference.Name)
+	}
+	codewindInstance.OwnerReferenceName = ownerReference.Name
+	codewindInstance.OwnerReferenceUID = ownerReference.UID
+	codewindInstance.OwnerReferenceKind = ownerReference.Kind
+	codewindInstance.OwnerReferenceAPIVersion = ownerReference.APIVersion
 
-	// Retrieve the images for PFE and Performance dashboard
-	pfe, performance := codewind.GetImages()
+	// Load the route certificates from the route TLS secret, if one was set. From here on, failing rolls back the
+	// anchor ConfigMap if this deployment created it
+	if codewindInstance.Exposure == codewind.ExposureRoute {
+		err = codewind.LoadRouteCertificates(clientset, namespace, &codewindInstance.Route)
+		if err != nil {
+			log.Errorf("Invalid Codewind route settings: %v\n", err)
+			failDeploy()
+		}
+	}
 
-	// Determine if we're running on OpenShift or not.
-	onOpenShift := kube.DetectOpenShift(config)
+	// Pick the ingress profile from the ingress controller, if it wasn't set. This is done before deploying,
+	// as some profiles annotate the PFE service as well as the ingress
+	if codewindInstance.Exposure == codewind.ExposureIngress && codewindInstance.IngressProfile == "" {
+		codewindInstance.IngressProfile = codewind.DetectIngressProfile(dynamicClient, codewindInstance.IngressClass)
+	}
 
-	// Create the Codewind deployment object
-	codewindInstance := codewind.Codewind{
-		PFEName:            constants.PFEPrefix + cheWorkspaceID,
-		PFEImage:           pfe,
-		PVCName:            constants.PFEPrefix + "-" + cheWorkspaceID,
-		PerformanceName:    constants.PerformancePrefix + cheWorkspaceID,
-		PerformanceImage:   performance,
-		Namespace:          namespace,
-		WorkspaceID:        cheWorkspaceID,
-		ServiceAccountName: serviceAccountName,
-		OwnerReferenceName: ownerReferenceName,
-		OwnerReferenceUID:  ownerReferenceUID,
-		Privileged:         true,
-		Ingress:            constants.PFEPrefix + "-" + cheWorkspaceID + "-" + cheIngress,
-		OnOpenShift:        onOpenShift,
-		CheIngress:         cheIngress,
+	// Make sure the Codewind pods are allowed in the namespace before creating anything
+	err = codewind.CheckPodSecurity(clientset, namespace, codewindInstance)
+	if err != nil {
+		log.Errorf("Codewind can't be deployed with its security profiles: %v\n", err)
+		failDeploy()
 	}
 
-	err = codewind.DeployCodewind(clientset, codewindInstance, namespace)
+	// Find the secret to pull the Codewind images with, and add it to the workspace service account if requested
+	codewindInstance.PullSecret, err = codewind.ResolvePullSecret(clientset, namespace, cheWorkspaceID, codewindInstance.PullSecret)
 	if err != nil {
-		log.Errorf("Codewind deployment failed, exiting...")
-		os.Exit(1)
+		log.Errorf("Unable to determine the image pull secret of Codewind: %v\n", err)
+		failDeploy()
+	}
+	if codewindInstance.PatchServiceAccount && codewindInstance.PullSecret != "" {
+		err = codewind.PatchServiceAccount(clientset, codewindInstance)
+		if err != nil {
+			log.Warnf("Unable to add image pull secret %s to service account %s, it's only set on the Codewind pods: %v\n", codewindInstance.PullSecret, codewindInstance.ServiceAccountName, err)
+		}
+	}
+
+	// Find the Che workspace volume to mount in PFE, if it's shared rather than PFE getting a volume of its own
+	if codewindInstance.ShareWorkspaceVolume {
+		codewindInstance.WorkspaceVolume, err = codewind.ResolveWorkspaceVolume(clientset, namespace, cheWorkspaceID)
+		if err != nil {
+			log.Errorf("Unable to share the Che workspace volume with Codewind: %v\n", err)
+			failDeploy()
+		}
+	}
+
+	err = codewind.DeployCodewind(clientset, codewindInstance, namespace, rollback)
+	if err != nil {
+		if pvcErr, ok := err.(*codewind.PVCNotBoundError); ok {
+			log.Errorf("Persistent volume claim %s was still %s after %v\n", pvcErr.Name, pvcErr.Phase, pvcErr.Timeout)
+			for _, event := range pvcErr.Events {
+				log.Errorf("  %s\n", event)
+			}
+		} else {
+			log.Errorf("%v\n", err)
+		}
+		failDeploy()
 	}
 
-	// Expose Codewind over an ingress or route
+	// Routes only exist on OpenShift
+	var routev1client routev1.RouteV1Interface
 	if onOpenShift {
-		// Deploy a route instead on OpenShift
-		route := codewind.CreateRoute(codewindInstance)
-		routev1client, err := routev1.NewForConfig(config)
+		routev1client, err = routev1.NewForConfig(kubeConfig)
 		if err != nil {
 			log.Errorf("Error retrieving route client for OpenShift: %v\n", err)
-			os.Exit(1)
+			failDeploy()
 		}
+	}
 
-		_, err = routev1client.Routes(namespace).Create(&route)
+	// Expose Codewind over a route, ingress or Gateway API route, or only through its service
+	switch codewindInstance.Exposure {
+	case codewind.ExposureRoute:
+		route := codewind.CreateRoute(codewindInstance)
+
+		err = codewind.ReconcileRoute(routev1client, route, namespace, rollback)
 		if err != nil {
-			log.Errorf("Error: Unable to create route for Codewind: %v\n", err)
-			os.Exit(1)
+			log.Errorf("Error: Unable to deploy route for Codewind: %v\n", err)
+			failDeploy()
 		}
 
-	} else {
+	case codewind.ExposureIngress:
 		ingress := codewind.CreateIngress(codewindInstance)
 
-		_, err = clientset.ExtensionsV1beta1().Ingresses(namespace).Create(&ingress)
+		err = codewind.ReconcileIngress(dynamicClient, ingress, namespace, rollback)
+		if err != nil {
+			log.Errorf("Error: Unable to deploy ingress for Codewind: %v\n", err)
+			failDeploy()
+		}
+
+	case codewind.ExposureGateway:
+		// Use the newest API version of the route kind served by the cluster
+		apiVersions, resource := codewind.GatewayRouteAPIVersions(codewindInstance.Gateway.RouteKind)
+		codewindInstance.Gateway.APIVersion, err = kube.DetectAPIVersion(clientset.Discovery(), resource, apiVersions)
+		if err != nil {
+			log.Errorf("Error: Unable to determine the %s API version, is the Gateway API installed? %v\n", codewindInstance.Gateway.RouteKind, err)
+			failDeploy()
+		}
+		route := codewind.CreateGatewayRoute(codewindInstance)
+
+		err = codewind.ReconcileGatewayRoute(dynamicClient, route, namespace, rollback)
 		if err != nil {
-			log.Errorf("Error: Unable to create ingress for Codewind: %v\n", err)
+			log.Errorf("Error: Unable to deploy %s for Codewind: %v\n", codewindInstance.Gateway.RouteKind, err)
+			failDeploy()
+		}
+
+	default:
+		// Remove any route or ingress left from a previous exposure strategy, so Codewind isn't reachable through it
+		_, err = codewind.RemoveExposure(clientset, dynamicClient, routev1client, namespace, cheWorkspaceID)
+		if err != nil {
+			log.Errorf("Error: Unable to remove the previous exposure of Codewind: %v\n", err)
+			failDeploy()
+		}
+		switch codewindInstance.Exposure {
+		case codewind.ExposureNone:
+			log.Infoln("Codewind is only reachable from inside the cluster")
+		case codewind.ExposureNodePort:
+			log.Infoln("Codewind is exposed on a node port of its service")
+		case codewind.ExposureLoadBalancer:
+			log.Infoln("Codewind is exposed on the load balancer address of its service, once it's assigned")
+		}
+	}
+
+}
+
+// newCodewind returns the Codewind instance to deploy for the given Che workspace, from the loaded settings
+func newCodewind(settings *config.Config, cheWorkspaceID string, namespace string, cheIngress string, serviceAccountName string, ownerReference metav1.OwnerReference, onOpenShift bool) (codewind.Codewind, error) {
+	// Retrieve the images for PFE and Performance dashboard, and how they're pulled
+	pfe, performance := codewind.GetImages(settings)
+	imagePullPolicy, err := codewind.GetImagePullPolicy(settings)
+	if err != nil {
+		return codewind.Codewind{}, err
+	}
+
+	// Retrieve the probe settings for PFE and Performance dashboard
+	pfeProbe, performanceProbe, err := codewind.GetProbes(settings)
+	if err != nil {
+		return codewind.Codewind{}, err
+	}
+
+	// Retrieve the CPU and memory requests and limits for PFE and Performance dashboard
+	pfeResources, performanceResources, err := codewind.GetResources(settings)
+	if err != nil {
+		return codewind.Codewind{}, err
+	}
+
+	// Retrieve the ingress profile, which is detected from the cluster when deploying if it isn't set
+	ingressProfile, err := codewind.ParseIngressProfile(settings.Ingress.Profile)
+	if err != nil {
+		return codewind.Codewind{}, err
+	}
+
+	// Generate the hostname that Codewind is exposed on
+	hostname, err := codewind.GenerateHostname(settings.HostnameTemplate, codewind.HostnameData{
+		Prefix:      constants.PFEPrefix,
+		WorkspaceID: cheWorkspaceID,
+		Namespace:   namespace,
+		CheDomain:   cheIngress,
+	})
+	if err != nil {
+		return codewind.Codewind{}, err
+	}
+
+	// Retrieve how Codewind is exposed, and the settings of the route or Gateway it's exposed through
+	exposure, err := codewind.GetExposureStrategy(settings, onOpenShift)
+	if err != nil {
+		return codewind.Codewind{}, err
+	}
+	var routeSettings codewind.RouteSettings
+	if exposure == codewind.ExposureRoute {
+		routeSettings, err = codewind.GetRouteSettings(settings)
+		if err != nil {
+			return codewind.Codewind{}, err
+		}
+	}
+	var gatewaySettings codewind.GatewaySettings
+	if exposure == codewind.ExposureGateway {
+		gatewaySettings, err = codewind.GetGatewaySettings(settings)
+		if err != nil {
+			return codewind.Codewind{}, err
+		}
+	}
+
+	// Retrieve the security profiles that the Codewind containers run with
+	pfeSecurityProfile, performanceSecurityProfile, err := codewind.GetSecurityProfiles(settings)
+	if err != nil {
+		return codewind.Codewind{}, err
+	}
+
+	// Retrieve how long to wait for the Codewind volume to be bound
+	pvcBindTimeout, err := codewind.GetPVCBindTimeout(settings)
+	if err != nil {
+		return codewind.Codewind{}, err
+	}
+
+	return codewind.Codewind{
+		PFEName:                    constants.PFEPrefix + cheWorkspaceID,
+		PFEImage:                   pfe,
+		PVCName:                    constants.PFEPrefix + "-" + cheWorkspaceID,
+		PerformanceName:            constants.PerformancePrefix + cheWorkspaceID,
+		PerformanceImage:           performance,
+		ImagePullPolicy:            imagePullPolicy,
+		Namespace:                  namespace,
+		WorkspaceID:                cheWorkspaceID,
+		ServiceAccountName:         serviceAccountName,
+		PullSecret:                 settings.PullSecret.Name,
+		PatchServiceAccount:        settings.PullSecret.PatchServiceAccount,
+		OwnerReferenceName:         ownerReference.Name,
+		OwnerReferenceUID:          ownerReference.UID,
+		OwnerReferenceKind:         ownerReference.Kind,
+		OwnerReferenceAPIVersion:   ownerReference.APIVersion,
+		PFESecurityProfile:         pfeSecurityProfile,
+		PerformanceSecurityProfile: performanceSecurityProfile,
+		Ingress:                    hostname,
+		OnOpenShift:                onOpenShift,
+		CheIngress:                 cheIngress,
+		PFEProbe:                   pfeProbe,
+		PerformanceProbe:           performanceProbe,
+		PFEResources:               pfeResources,
+		PerformanceResources:       performanceResources,
+		IngressClass:               settings.Ingress.Class,
+		IngressTLSSecret:           settings.Ingress.TLSSecret,
+		StorageClass:               settings.Volume.StorageClass,
+		VolumeSize:                 settings.Volume.Size,
+		ShareWorkspaceVolume:       settings.Volume.ShareWorkspaceVolume,
+		PVCBindTimeout:             pvcBindTimeout,
+		IngressProfile:             ingressProfile,
+		IngressAnnotations:         settings.Ingress.Annotations,
+		Route:                      routeSettings,
+		Exposure:                   exposure,
+		Gateway:                    gatewaySettings,
+	}, nil
+}
+
+// render prints the manifests that would be deployed for a Che workspace, built from the settings and flags that were
+// passed in instead of from the cluster, so that they can be reviewed before deploy-pfe is allowed to apply them
+func render(flags *flag.FlagSet, configLoader *config.Loader, args []string) {
+	namespace := flags.String("namespace", "default", "namespace that Codewind would be deployed in")
+	ingress := flags.String("ingress", "", "ingress domain used by Che (default the domain of the Che API)")
+	onOpenShift := flags.Bool("openshift", false, "render an OpenShift route instead of an ingress")
+	serviceAccountName := flags.String("service-account", "che-workspace", "service account of the Che workspace")
+	ownerReferenceKind := flags.String("owner-kind", "Deployment", "kind of the workspace object that owns the Codewind resources")
+	ownerReferenceAPIVersion := flags.String("owner-api-version", "apps/v1", "API version of the workspace object that owns the Codewind resources")
+	ownerReferenceName := flags.String("owner-name", "", "name of the workspace object that owns the Codewind resources")
+	ownerReferenceUID := flags.String("owner-uid", "", "UID of the workspace object that owns the Codewind resources, no owner references are rendered if not set")
+	workspacePVCName := flags.String("workspace-pvc", "claim-che-workspace", "name of the Che workspace PVC that owns the Codewind PVC")
+	workspacePVCUID := flags.String("workspace-pvc-uid", "", "UID of the Che workspace PVC that owns the Codewind PVC, the PVC has no owner reference if not set")
+	ingressAPIVersion := flags.String("ingress-api-version", constants.IngressAPIVersion, "API version of the rendered ingress")
+	output := flags.String("output", "yaml", "output format, yaml or json")
+	flags.Parse(args)
+	settings := loadConfig(configLoader)
+
+	workspaceID := settings.Workspace.ID
+	if workspaceID == "" {
+		log.Errorln("Che Workspace ID not set and unable to render Codewind, exiting...")
+		os.Exit(1)
+	}
+	// Default the Che ingress domain to the one of the current workspace, if we're running in one
+	cheIngress := *ingress
+	if cheIngress == "" {
+		cheIngress, _ = che.GetCheIngress(settings.Workspace.CheAPI)
+	}
+	if cheIngress == "" {
+		log.Errorln("Che ingress domain not set and unable to render Codewind, exiting...")
+		os.Exit(1)
+	}
+
+	ownerReference := metav1.OwnerReference{
+		Kind:       *ownerReferenceKind,
+		APIVersion: *ownerReferenceAPIVersion,
+		Name:       *ownerReferenceName,
+		UID:        types.UID(*ownerReferenceUID),
+	}
+	codewindInstance, err := newCodewind(settings, workspaceID, *namespace, cheIngress, *serviceAccountName, ownerReference, *onOpenShift)
+	if err != nil {
+		log.Errorf("Invalid Codewind settings: %v\n", err)
+		os.Exit(1)
+	}
+	codewindInstance.IngressAPIVersion = *ingressAPIVersion
+	if codewindInstance.IngressProfile == "" {
+		codewindInstance.IngressProfile = constants.IngressProfile
+	}
+	if codewindInstance.Route.TLSSecret != "" {
+		log.Warnf("The certificates of route TLS secret %s are only added to the route when deploying, and aren't rendered\n", codewindInstance.Route.TLSSecret)
+	}
+	if codewindInstance.ShareWorkspaceVolume {
+		codewindInstance.WorkspaceVolume = &codewind.WorkspaceVolume{ClaimName: *workspacePVCName, SubPath: workspaceID + "/projects"}
+		log.Warnf("Codewind is rendered sharing Che workspace volume %s at subpath %s, the subpath is only read from the workspace pod when deploying\n", *workspacePVCName, codewindInstance.WorkspaceVolume.SubPath)
+	}
+	objects := codewind.RenderManifests(codewindInstance, codewindInstance.StorageClass, *workspacePVCName, types.UID(*workspacePVCUID))
+	err = codewind.WriteManifests(os.Stdout, objects, *output)
+	if err != nil {
+		log.Errorf("Unable to render Codewind manifests: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// waitForCodewind waits for the Codewind deployments of the workspace to become available, exiting with an error
+// if they aren't available before the timeout
+func waitForCodewind(clientset kubernetes.Interface, namespace string, cheWorkspaceID string, timeout time.Duration) {
+	err := codewind.WaitForCodewind(clientset, namespace, cheWorkspaceID, timeout)
+	if err != nil {
+		log.Errorf("%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// teardown removes the Codewind resources belonging to a Che workspace, and prints the resources that were removed
+func teardown(kubeConfig *rest.Config, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, workspaceID string, keepPVC bool) {
+	if workspaceID == "" {
+		log.Errorln("Che Workspace ID not set and unable to tear down Codewind, exiting...")
+		os.Exit(1)
+	}
+
+	// Routes only exist on OpenShift
+	var routeClient routev1.RouteV1Interface
+	onOpenShift, err := kube.DetectOpenShift(kubeConfig)
+	if err != nil {
+		log.Errorf("Unable to detect if running on OpenShift: %v\n", err)
+		os.Exit(1)
+	}
+	if onOpenShift {
+		routeClient, err = routev1.NewForConfig(kubeConfig)
+		if err != nil {
+			log.Errorf("Error retrieving route client for OpenShift: %v\n", err)
 			os.Exit(1)
 		}
+	}
+
+	log.Infof("Tearing down Codewind for workspace %s in namespace %s\n", workspaceID, namespace)
+	removed, err := codewind.TeardownCodewind(clientset, dynamicClient, routeClient, namespace, workspaceID, keepPVC)
+	for _, resource := range removed {
+		fmt.Println(resource)
+	}
+	if err != nil {
+		log.Errorf("Codewind teardown failed: %v\n", err)
+		os.Exit(1)
+	}
+	log.Infof("Removed %d Codewind resources\n", len(removed))
+}
+
+// preflight checks that the workspace service account has every permission that deploying Codewind needs, printing a
+// table of the missing ones matched against the Codewind cluster roles, read from the given manifest or from the
+// cluster if it's empty. Returns false if any permission is missing
+func preflight(clientset kubernetes.Interface, codewindInstance codewind.Codewind, ownershipStrategy codewind.OwnershipStrategy, clusterRolePath string) bool {
+	missing, err := codewind.CheckPermissions(clientset, codewind.RequiredPermissions(codewindInstance, ownershipStrategy))
+	if err != nil {
+		log.Warnf("Unable to check the permissions of the Che workspace service account: %v\n", err)
+		return true
+	}
+	if len(missing) == 0 {
+		log.Infoln("The Che workspace service account has every permission needed to deploy Codewind")
+		return true
+	}
+
+	// Match the missing permissions against the cluster roles, to tell whether they aren't bound or are out of date
+	var clusterRoles []*rbacv1.ClusterRole
+	if clusterRolePath != "" {
+		clusterRoles, err = codewind.LoadClusterRoles(clusterRolePath)
+	} else {
+		clusterRoles, err = codewind.GetClusterRoles(clientset)
+	}
+	if err != nil {
+		log.Warnf("Unable to read cluster roles %s and %s to match the missing permissions against: %v\n", codewind.ClusterRoleName, codewind.ClusterScopedRoleName, err)
+		clusterRoles = nil
+	}
+
+	log.Errorf("The Che workspace service account is missing %d permissions needed to deploy Codewind:\n", len(missing))
+	err = codewind.WritePermissionTable(os.Stdout, missing, clusterRoles)
+	if err != nil {
+		log.Errorf("Unable to print the missing permissions: %v\n", err)
+	}
+	return false
+}
 
+// loadConfig loads the settings from the config file, the environment and the flags once they've been parsed. Exits
+// if a setting is invalid
+func loadConfig(configLoader *config.Loader) *config.Config {
+	settings, err := configLoader.Load()
+	if err != nil {
+		log.Errorf("Invalid Codewind settings: %v\n", err)
+		os.Exit(1)
+	}
+	return settings
+}
+
+// validateSettings checks every Codewind setting without connecting to the cluster. Settings that depend on the
+// cluster, such as the route exposure only being available on OpenShift, are checked again when deploying
+func validateSettings(settings *config.Config) error {
+	_, err := codewind.GetOwnershipStrategy(settings)
+	if err != nil {
+		return err
 	}
 
+	// The hostname template is checked against the workspace and Che domain, or placeholders if they aren't known yet
+	workspaceID := settings.Workspace.ID
+	if workspaceID == "" {
+		workspaceID = "workspace"
+	}
+	cheIngress, err := che.GetCheIngress(settings.Workspace.CheAPI)
+	if err != nil {
+		cheIngress = "che.example.com"
+	}
+	_, err = newCodewind(settings, workspaceID, "default", cheIngress, "", metav1.OwnerReference{}, true)
+	return err
+}
+
+// printConfig prints every setting with its effective value and where it was taken from, and checks the settings
+func printConfig(flags *flag.FlagSet, configLoader *config.Loader, args []string) {
+	flags.Parse(args)
+	settings := loadConfig(configLoader)
+
+	err := settings.WriteTable(os.Stdout)
+	if err != nil {
+		log.Errorf("Unable to print the Codewind settings: %v\n", err)
+		os.Exit(1)
+	}
+	err = validateSettings(settings)
+	if err != nil {
+		log.Errorf("Invalid Codewind settings: %v\n", err)
+		os.Exit(1)
+	}
 }
@@ -3,49 +3,81 @@ package che
 import (
 	"fmt"
 	"net/url"
-	"os"
+	"strings"
 
-	"deploy-pfe/pkg/kube"
+	"deploy-pfe/pkg/constants"
 
 	log "github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	types "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 )
 
 // GetWorkspacePVC retrieves a PVC (Persistent Volume Claim) associated with the Che workspace we're deploying Codewind in
-func GetWorkspacePVC(clientset *kubernetes.Clientset, namespace string, cheWorkspaceID string) *corev1.PersistentVolumeClaim {
+func GetWorkspacePVC(clientset kubernetes.Interface, namespace string, cheWorkspaceID string) (*corev1.PersistentVolumeClaim, error) {
 	PVCs, err := clientset.CoreV1().PersistentVolumeClaims(namespace).List(metav1.ListOptions{
 		LabelSelector: "che.workspace_id=" + cheWorkspaceID,
 	})
-	if err != nil || PVCs == nil {
-		log.Errorf("Error, unable to retrieve PVCs: %v\n", err)
-		os.Exit(1)
+	if err != nil {
+		return nil, &WorkspacePVCNotFoundError{WorkspaceID: cheWorkspaceID, Err: err}
 	} else if len(PVCs.Items) < 1 {
 		// We couldn't find the workspace PVC, so need to find an alternative.
 		PVC, err := clientset.CoreV1().PersistentVolumeClaims(namespace).Get("claim-che-workspace", metav1.GetOptions{})
-		if err != nil || PVC == nil {
-			log.Errorf("Error, unable to retrieve PVCs: %v\n", err)
-			os.Exit(1)
-		} else {
-			return PVC
+		if err != nil {
+			return nil, &WorkspacePVCNotFoundError{WorkspaceID: cheWorkspaceID, Err: err}
 		}
+		return PVC, nil
 	}
-	return &PVCs.Items[0]
+	return &PVCs.Items[0], nil
+}
+
+// projectsMountPath is where the Che workspace volume holding the projects is mounted in the workspace containers
+const projectsMountPath = "/projects"
+
+// GetWorkspaceProjectsVolume retrieves the PVC holding the projects of the Che workspace we're deploying Codewind in, and
+// the subpath of the projects on it, from the /projects volume mount of the workspace pod
+func GetWorkspaceProjectsVolume(clientset kubernetes.Interface, namespace string, cheWorkspaceID string) (*corev1.PersistentVolumeClaim, string, error) {
+	workspacePod, err := clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{
+		LabelSelector: "che.workspace_id=" + cheWorkspaceID,
+	})
+	if err != nil {
+		return nil, "", &WorkspacePodNotFoundError{WorkspaceID: cheWorkspaceID, Err: err}
+	} else if len(workspacePod.Items) < 1 {
+		return nil, "", &WorkspacePodNotFoundError{WorkspaceID: cheWorkspaceID}
+	}
+	pod := workspacePod.Items[0]
+
+	// Find the volume mounted at /projects, and the PVC backing it
+	for _, container := range pod.Spec.Containers {
+		for _, volumeMount := range container.VolumeMounts {
+			if volumeMount.MountPath != projectsMountPath {
+				continue
+			}
+			for _, volume := range pod.Spec.Volumes {
+				if volume.Name != volumeMount.Name || volume.PersistentVolumeClaim == nil {
+					continue
+				}
+				PVC, err := clientset.CoreV1().PersistentVolumeClaims(namespace).Get(volume.PersistentVolumeClaim.ClaimName, metav1.GetOptions{})
+				if err != nil {
+					return nil, "", &WorkspacePVCNotFoundError{WorkspaceID: cheWorkspaceID, Err: err}
+				}
+				return PVC, volumeMount.SubPath, nil
+			}
+		}
+	}
+	return nil, "", &ProjectsVolumeNotFoundError{WorkspaceID: cheWorkspaceID, PodName: pod.GetName()}
 }
 
 // GetWorkspaceServiceAccount retrieves the Service Account associated with the Che workspace we're deploying Codewind in
-func GetWorkspaceServiceAccount(clientset *kubernetes.Clientset, namespace string, cheWorkspaceID string) string {
+func GetWorkspaceServiceAccount(clientset kubernetes.Interface, namespace string, cheWorkspaceID string) (string, error) {
 	var serviceAccountName string
 
 	// Retrieve the workspace service account labeled with the Che Workspace ID
 	workspacePod, err := clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{
 		LabelSelector: "che.workspace_id=" + cheWorkspaceID,
 	})
-	if err != nil || workspacePod == nil {
-		log.Errorf("Error retrieving the Che workspace pod %v\n", err)
-		os.Exit(1)
+	if err != nil {
+		return "", &WorkspacePodNotFoundError{WorkspaceID: cheWorkspaceID, Err: err}
 	} else if len(workspacePod.Items) != 1 {
 		// Default to che-workspace as the Service Account name if one couldn't be found
 		serviceAccountName = "che-workspace"
@@ -53,29 +85,58 @@ func GetWorkspaceServiceAccount(clientset *kubernetes.Clientset, namespace strin
 		serviceAccountName = workspacePod.Items[0].Spec.ServiceAccountName
 	}
 
-	return serviceAccountName
-
+	return serviceAccountName, nil
 }
 
-// GetOwnerReferences retrieves the owner reference name and UID, allowing us to tie any Codewind resources to the Che workspace
-// Enabling the Kubernetes garbage collector clean everything up when the workspace is deleted
-func GetOwnerReferences(clientset *kubernetes.Clientset, namespace string, cheWorkspaceID string) (string, types.UID) {
+// GetOwnerReferences retrieves the most durable owner of the Che workspace pod, allowing us to tie any Codewind resources to
+// the Che workspace. Enabling the Kubernetes garbage collector clean everything up when the workspace is deleted.
+// The pod's owner chain is walked up as far as possible (Pod -> ReplicaSet -> Deployment), as a ReplicaSet is replaced
+// whenever its Deployment rolls out, while the Deployment lives as long as the workspace
+func GetOwnerReferences(clientset kubernetes.Interface, namespace string, cheWorkspaceID string) (metav1.OwnerReference, error) {
 	// Get the Workspace pod
-	var ownerReferenceName string
-	var ownerReferenceUID types.UID
-
 	workspacePod, err := clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{
 		LabelSelector: "che.workspace_id=" + cheWorkspaceID,
 	})
 	if err != nil {
-		log.Errorf("Error: Unable to retrieve the workspace pod %v\n", err)
-		os.Exit(1)
+		return metav1.OwnerReference{}, &WorkspacePodNotFoundError{WorkspaceID: cheWorkspaceID, Err: err}
+	} else if len(workspacePod.Items) < 1 {
+		return metav1.OwnerReference{}, &WorkspacePodNotFoundError{WorkspaceID: cheWorkspaceID}
+	}
+
+	// Retrieve the owner reference from the workspace pod. This will allow Codewind to be garbage collected by Kube
+	owner := controllerOf(workspacePod.Items[0].GetOwnerReferences())
+	if owner == nil {
+		return metav1.OwnerReference{}, &NoOwnerReferencesError{WorkspaceID: cheWorkspaceID, PodName: workspacePod.Items[0].GetName()}
+	}
+
+	// Walk up the owner chain, for as long as the owners are kinds that we know how to look up
+	for owner.Kind == "ReplicaSet" && strings.HasPrefix(owner.APIVersion, "apps/") {
+		replicaSet, err := clientset.AppsV1().ReplicaSets(namespace).Get(owner.Name, metav1.GetOptions{})
+		if err != nil {
+			log.Warnf("Unable to retrieve the owner of ReplicaSet %s, Codewind will be owned by the ReplicaSet instead: %v\n", owner.Name, err)
+			break
+		}
+		parent := controllerOf(replicaSet.GetOwnerReferences())
+		if parent == nil {
+			break
+		}
+		owner = parent
 	}
-	// Retrieve the owner reference name and UID from the workspace pod. This will allow Codewind to be garbage collected by Kube
-	ownerReferenceName = workspacePod.Items[0].GetOwnerReferences()[0].Name
-	ownerReferenceUID = workspacePod.Items[0].GetOwnerReferences()[0].UID
+	return *owner, nil
+}
 
-	return ownerReferenceName, ownerReferenceUID
+// controllerOf returns the owner reference that manages an object, or the first owner reference if none of them is
+// marked as the controller. nil is returned if the object has no owners
+func controllerOf(ownerReferences []metav1.OwnerReference) *metav1.OwnerReference {
+	if len(ownerReferences) < 1 {
+		return nil
+	}
+	for i := range ownerReferences {
+		if ownerReferences[i].Controller != nil && *ownerReferences[i].Controller {
+			return &ownerReferences[i]
+		}
+	}
+	return &ownerReferences[0]
 }
 
 // GetCheIngress parses the Che ingress domain from the Che API URL that was passed in
@@ -101,12 +162,36 @@ func GetCheIngress(cheAPI string) (string, error) {
 }
 
 // GetPFEService returns the service name for the specified workspace ID
-func GetPFEService(clientset *kubernetes.Clientset, namespace string, workspaceID string) string {
-	service, err := clientset.CoreV1().Services(kube.GetCurrentNamespace()).List(metav1.ListOptions{
+func GetPFEService(clientset kubernetes.Interface, namespace string, workspaceID string) string {
+	service := getPFEService(clientset, namespace, workspaceID)
+	if service == nil {
+		return ""
+	}
+	return service.GetName()
+}
+
+// GetPFEEndpoint returns the URL of the PFE service for the specified workspace ID. PFE serves plain HTTP rather than
+// HTTPS on its HTTP port, when TLS is terminated in front of it (by an edge route or a Gateway)
+func GetPFEEndpoint(clientset kubernetes.Interface, namespace string, workspaceID string) string {
+	service := getPFEService(clientset, namespace, workspaceID)
+	if service == nil || len(service.Spec.Ports) < 1 {
+		return ""
+	}
+	port := service.Spec.Ports[0].Port
+	scheme := "https"
+	if port == constants.PFEHTTPPort {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, service.GetName(), port)
+}
+
+// getPFEService returns the PFE service for the specified workspace ID, or nil if it doesn't exist
+func getPFEService(clientset kubernetes.Interface, namespace string, workspaceID string) *corev1.Service {
+	service, err := clientset.CoreV1().Services(namespace).List(metav1.ListOptions{
 		LabelSelector: "app=codewind-pfe,codewindWorkspace=" + workspaceID,
 	})
 	if err != nil || len(service.Items) < 1 {
-		return ""
+		return nil
 	}
-	return service.Items[0].GetName()
+	return &service.Items[0]
 }
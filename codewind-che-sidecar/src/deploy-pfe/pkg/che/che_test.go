@@ -3,6 +3,14 @@ package che
 import (
 	"fmt"
 	"testing"
+
+	"deploy-pfe/pkg/constants"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 var CheIngress = "che-eclipse-che.9.1.2.3.nip.io"
@@ -67,3 +75,312 @@ func TestParseInvalidCheAPI(t *testing.T) {
 		})
 	}
 }
+
+var WorkspaceID = "workspace1erok6723m74axkg"
+
+// setupWorkspacePod returns a Che workspace pod owned by the given owner references
+func setupWorkspacePod(ownerReferences []metav1.OwnerReference) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            WorkspaceID + ".ws-7d8b6c8f5-x2x7h",
+			Namespace:       "default",
+			Labels:          map[string]string{"che.workspace_id": WorkspaceID},
+			OwnerReferences: ownerReferences,
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: "che-workspace-sa",
+		},
+	}
+}
+
+func TestGetPFEService(t *testing.T) {
+	pfeService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "codewind-" + WorkspaceID,
+			Namespace: "default",
+			Labels:    map[string]string{"app": "codewind-pfe", "codewindWorkspace": WorkspaceID},
+		},
+	}
+	tests := []struct {
+		name        string
+		objects     []runtime.Object
+		namespace   string
+		serviceName string
+	}{
+		{
+			name:        fmt.Sprintf("Find the PFE service of the workspace"),
+			objects:     []runtime.Object{pfeService},
+			namespace:   "default",
+			serviceName: "codewind-" + WorkspaceID,
+		},
+		{
+			name:        fmt.Sprintf("Don't find the PFE service in another namespace"),
+			objects:     []runtime.Object{pfeService},
+			namespace:   "che",
+			serviceName: "",
+		},
+		{
+			name:        fmt.Sprintf("Don't find a PFE service that doesn't exist"),
+			objects:     []runtime.Object{},
+			namespace:   "default",
+			serviceName: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.objects...)
+			serviceName := GetPFEService(clientset, tt.namespace, WorkspaceID)
+			if serviceName != tt.serviceName {
+				t.Errorf("GetPFEService returned %q, expected %q", serviceName, tt.serviceName)
+			}
+		})
+	}
+}
+
+func TestGetPFEEndpoint(t *testing.T) {
+	pfeService := func(port int32) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "codewind-" + WorkspaceID,
+				Namespace: "default",
+				Labels:    map[string]string{"app": "codewind-pfe", "codewindWorkspace": WorkspaceID},
+			},
+			Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: port}}},
+		}
+	}
+	tests := []struct {
+		name     string
+		objects  []runtime.Object
+		endpoint string
+	}{
+		{
+			name:     fmt.Sprintf("PFE serving HTTPS"),
+			objects:  []runtime.Object{pfeService(constants.PFEContainerPort)},
+			endpoint: "https://codewind-" + WorkspaceID + ":9191",
+		},
+		{
+			name:     fmt.Sprintf("PFE serving plain HTTP behind an edge route"),
+			objects:  []runtime.Object{pfeService(constants.PFEHTTPPort)},
+			endpoint: "http://codewind-" + WorkspaceID + ":9090",
+		},
+		{
+			name:     fmt.Sprintf("No PFE service"),
+			endpoint: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.objects...)
+			endpoint := GetPFEEndpoint(clientset, "default", WorkspaceID)
+			if endpoint != tt.endpoint {
+				t.Errorf("GetPFEEndpoint returned %q, expected %q", endpoint, tt.endpoint)
+			}
+		})
+	}
+}
+
+func TestGetWorkspaceServiceAccount(t *testing.T) {
+	tests := []struct {
+		name               string
+		objects            []runtime.Object
+		serviceAccountName string
+	}{
+		{
+			name:               fmt.Sprintf("Use the service account of the workspace pod"),
+			objects:            []runtime.Object{setupWorkspacePod(nil)},
+			serviceAccountName: "che-workspace-sa",
+		},
+		{
+			name:               fmt.Sprintf("Default to che-workspace without a workspace pod"),
+			objects:            []runtime.Object{},
+			serviceAccountName: "che-workspace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.objects...)
+			serviceAccountName, err := GetWorkspaceServiceAccount(clientset, "default", WorkspaceID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if serviceAccountName != tt.serviceAccountName {
+				t.Errorf("GetWorkspaceServiceAccount returned %q, expected %q", serviceAccountName, tt.serviceAccountName)
+			}
+		})
+	}
+}
+
+func TestGetOwnerReferencesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		check   func(error) bool
+	}{
+		{
+			name:    fmt.Sprintf("Workspace pod not found"),
+			objects: []runtime.Object{},
+			check: func(err error) bool {
+				_, ok := err.(*WorkspacePodNotFoundError)
+				return ok
+			},
+		},
+		{
+			name:    fmt.Sprintf("Workspace pod without owner references"),
+			objects: []runtime.Object{setupWorkspacePod(nil)},
+			check: func(err error) bool {
+				_, ok := err.(*NoOwnerReferencesError)
+				return ok
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.objects...)
+			_, err := GetOwnerReferences(clientset, "default", WorkspaceID)
+			if !tt.check(err) {
+				t.Errorf("GetOwnerReferences returned unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestGetOwnerReferences(t *testing.T) {
+	controller := true
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      WorkspaceID + ".ws-7d8b6c8f5",
+			Namespace: "default",
+			UID:       "c22d4a29-ba20-11e9-ac2a-005056a04e5e",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "Deployment", Name: WorkspaceID + ".ws", UID: "b1f3c1a4-ba20-11e9-ac2a-005056a04e5e", Controller: &controller},
+			},
+		},
+	}
+	orphanedReplicaSet := replicaSet.DeepCopy()
+	orphanedReplicaSet.OwnerReferences = nil
+	podOwner := []metav1.OwnerReference{
+		{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: replicaSet.GetName(), UID: replicaSet.GetUID(), Controller: &controller},
+	}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		kind    string
+		owner   string
+	}{
+		{
+			name:    fmt.Sprintf("Use the Deployment that owns the workspace ReplicaSet"),
+			objects: []runtime.Object{setupWorkspacePod(podOwner), replicaSet},
+			kind:    "Deployment",
+			owner:   WorkspaceID + ".ws",
+		},
+		{
+			name:    fmt.Sprintf("Use the ReplicaSet if it isn't owned by a Deployment"),
+			objects: []runtime.Object{setupWorkspacePod(podOwner), orphanedReplicaSet},
+			kind:    "ReplicaSet",
+			owner:   replicaSet.GetName(),
+		},
+		{
+			name:    fmt.Sprintf("Use the ReplicaSet if it can't be retrieved"),
+			objects: []runtime.Object{setupWorkspacePod(podOwner)},
+			kind:    "ReplicaSet",
+			owner:   replicaSet.GetName(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.objects...)
+			owner, err := GetOwnerReferences(clientset, "default", WorkspaceID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if owner.Kind != tt.kind || owner.Name != tt.owner {
+				t.Errorf("GetOwnerReferences returned %v %v, expected %v %v", owner.Kind, owner.Name, tt.kind, tt.owner)
+			}
+		})
+	}
+}
+
+func TestGetWorkspaceProjectsVolume(t *testing.T) {
+	projectsPod := setupWorkspacePod(nil)
+	projectsPod.Spec.Volumes = []corev1.Volume{
+		{
+			Name: "plugins",
+		},
+		{
+			Name: "claim-che-workspace",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "claim-che-workspace"},
+			},
+		},
+	}
+	projectsPod.Spec.Containers = []corev1.Container{
+		{
+			Name:         "theia-ide",
+			VolumeMounts: []corev1.VolumeMount{{Name: "plugins", MountPath: "/plugins"}, {Name: "claim-che-workspace", MountPath: "/projects", SubPath: WorkspaceID + "/projects"}},
+		},
+	}
+	workspacePVC := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "claim-che-workspace",
+			Namespace: "default",
+		},
+	}
+
+	tests := []struct {
+		name      string
+		objects   []runtime.Object
+		claimName string
+		subPath   string
+		check     func(error) bool
+	}{
+		{
+			name:      fmt.Sprintf("PVC mounted at /projects"),
+			objects:   []runtime.Object{projectsPod, workspacePVC},
+			claimName: "claim-che-workspace",
+			subPath:   WorkspaceID + "/projects",
+		},
+		{
+			name:    fmt.Sprintf("Workspace pod not found"),
+			objects: []runtime.Object{},
+			check: func(err error) bool {
+				_, ok := err.(*WorkspacePodNotFoundError)
+				return ok
+			},
+		},
+		{
+			name:    fmt.Sprintf("Workspace pod without a PVC mounted at /projects"),
+			objects: []runtime.Object{setupWorkspacePod(nil)},
+			check: func(err error) bool {
+				_, ok := err.(*ProjectsVolumeNotFoundError)
+				return ok
+			},
+		},
+		{
+			name:    fmt.Sprintf("PVC mounted at /projects not found"),
+			objects: []runtime.Object{projectsPod},
+			check: func(err error) bool {
+				_, ok := err.(*WorkspacePVCNotFoundError)
+				return ok
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.objects...)
+			pvc, subPath, err := GetWorkspaceProjectsVolume(clientset, "default", WorkspaceID)
+			if tt.check != nil {
+				if !tt.check(err) {
+					t.Errorf("GetWorkspaceProjectsVolume returned unexpected error %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pvc.Name != tt.claimName || subPath != tt.subPath {
+				t.Errorf("GetWorkspaceProjectsVolume returned %s with subpath %q, expected %s with subpath %q", pvc.Name, subPath, tt.claimName, tt.subPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package che
+
+import "fmt"
+
+// WorkspacePodNotFoundError is returned when the pod of the Che workspace can't be retrieved
+type WorkspacePodNotFoundError struct {
+	WorkspaceID string
+	Err         error
+}
+
+func (e *WorkspacePodNotFoundError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("unable to retrieve the pod of Che workspace %s: %v", e.WorkspaceID, e.Err)
+	}
+	return fmt.Sprintf("workspace pod not found for Che workspace %s", e.WorkspaceID)
+}
+
+// WorkspacePVCNotFoundError is returned when the persistent volume claim of the Che workspace can't be retrieved
+type WorkspacePVCNotFoundError struct {
+	WorkspaceID string
+	Err         error
+}
+
+func (e *WorkspacePVCNotFoundError) Error() string {
+	return fmt.Sprintf("unable to retrieve the persistent volume claim of Che workspace %s: %v", e.WorkspaceID, e.Err)
+}
+
+// NoOwnerReferencesError is returned when the pod of the Che workspace isn't owned by anything that Codewind
+// resources could be tied to
+type NoOwnerReferencesError struct {
+	WorkspaceID string
+	PodName     string
+}
+
+func (e *NoOwnerReferencesError) Error() string {
+	return fmt.Sprintf("no owner references on pod %s of Che workspace %s", e.PodName, e.WorkspaceID)
+}
+
+// ProjectsVolumeNotFoundError is returned when none of the containers of the Che workspace pod mount a persistent
+// volume claim at /projects
+type ProjectsVolumeNotFoundError struct {
+	WorkspaceID string
+	PodName     string
+}
+
+func (e *ProjectsVolumeNotFoundError) Error() string {
+	return fmt.Sprintf("no persistent volume claim is mounted at %s in pod %s of Che workspace %s", projectsMountPath, e.PodName, e.WorkspaceID)
+}
@@ -13,43 +13,30 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-// DeployCodewind takes in a `codewind` object and deploys Codewind and the performance dashboard into the specified namespace
-func DeployCodewind(clientset *kubernetes.Clientset, codewind Codewind, namespace string) error {
-	// See if a PVC for the PFE workspace already exists, if not, create one
-	_, err := clientset.CoreV1().PersistentVolumeClaims(namespace).Get(codewind.PVCName, metav1.GetOptions{})
-	if err != nil {
-		// Create a PVC for PFE
-		// Determine if we're running on OpenShift on IKS (and thus need to use the ibm-file-bronze storage class)
-		storageClass := ""
-		sc, err := clientset.StorageV1().StorageClasses().Get(constants.ROKSStorageClass, metav1.GetOptions{})
-		if err == nil && sc != nil {
-			storageClass = sc.Name
-			log.Infof("Setting storage class to %s\n", storageClass)
-		}
-
-		// Get the name and uid for the Che workspace volume
-		chePvc := che.GetWorkspacePVC(clientset, namespace, codewind.WorkspaceID)
-		pvc := generatePVC(codewind, constants.PFEVolumeSize, storageClass, chePvc.GetObjectMeta().GetName(), chePvc.GetObjectMeta().GetUID())
-		_, err = clientset.CoreV1().PersistentVolumeClaims(namespace).Create(&pvc)
+// DeployCodewind takes in a `codewind` object and deploys Codewind and the performance dashboard into the specified namespace.
+// Resources that already exist (such as after a workspace restart) are updated in place if they have drifted, and left alone otherwise
+func DeployCodewind(clientset kubernetes.Interface, codewind Codewind, namespace string, rollback *Rollback) error {
+	// PFE gets a PVC of its own, unless it shares the Che workspace PVC
+	if codewind.WorkspaceVolume == nil {
+		err := deployPFEVolume(clientset, codewind, namespace, rollback)
 		if err != nil {
-			log.Errorf("Unable to create Persistent Volume Claim for PFE: %v\n", err)
 			return err
 		}
 	}
 
-	// Deploy Codewind PFE
+	// Deploy Codewind PFE, or update the existing deployment if this workspace already has one
 	service := createPFEService(codewind)
 	deploy := createPFEDeploy(codewind)
 
 	log.Infoln("Deploying Codewind...")
-	_, err = clientset.CoreV1().Services(namespace).Create(&service)
+	err := reconcileService(clientset, service, rollback)
 	if err != nil {
-		log.Errorf("Unable to create Codewind service: %v\n", err)
+		log.Errorf("Unable to deploy Codewind service: %v\n", err)
 		return err
 	}
-	_, err = clientset.AppsV1().Deployments(namespace).Create(&deploy)
+	err = reconcileDeployment(clientset, deploy, rollback)
 	if err != nil {
-		log.Errorf("Unable to create Codewind deployment: %v\n", err)
+		log.Errorf("Unable to deploy Codewind deployment: %v\n", err)
 		return err
 	}
 
@@ -58,19 +45,67 @@ func DeployCodewind(clientset *kubernetes.Clientset, codewind Codewind, namespac
 	performanceDeploy := createPerformanceDeploy(codewind)
 
 	log.Infoln("Deploying Codewind Performance Dashboard...")
-	_, err = clientset.CoreV1().Services(namespace).Create(&performanceService)
+	err = reconcileService(clientset, performanceService, rollback)
 	if err != nil {
-		log.Errorf("Error: Unable to create Codewind Performance service: %v\n", err)
+		log.Errorf("Error: Unable to deploy Codewind Performance service: %v\n", err)
 		return err
 	}
-	_, err = clientset.AppsV1().Deployments(namespace).Create(&performanceDeploy)
+	err = reconcileDeployment(clientset, performanceDeploy, rollback)
 	if err != nil {
-		log.Errorf("Error: Unable to create Codewind Performance deployment: %v\n", err)
+		log.Errorf("Error: Unable to deploy Codewind Performance deployment: %v\n", err)
 		return err
 	}
 	return nil
 }
 
+// deployPFEVolume creates the PVC of the PFE workspace, or expands the existing one if a larger size was configured,
+// and waits for it to be bound
+func deployPFEVolume(clientset kubernetes.Interface, codewind Codewind, namespace string, rollback *Rollback) error {
+	// See if a PVC for the PFE workspace already exists, if not, create one
+	existingPVC, err := clientset.CoreV1().PersistentVolumeClaims(namespace).Get(codewind.PVCName, metav1.GetOptions{})
+	if err == nil {
+		// Grow the existing PVC if a larger volume was configured since it was created
+		err = ExpandPVC(clientset, existingPVC, volumeSizeOf(codewind))
+		if err != nil {
+			log.Errorf("Unable to expand Persistent Volume Claim for PFE: %v\n", err)
+			return err
+		}
+	} else {
+		// Create a PVC for PFE
+		// Determine the storage class to use, as PFE needs a volume that supports ReadWriteMany
+		storageClass, err := ResolveStorageClass(clientset, codewind.StorageClass)
+		if err != nil {
+			log.Errorf("Unable to create Persistent Volume Claim for PFE: %v\n", err)
+			return err
+		}
+
+		// Get the name and uid for the Che workspace volume
+		chePvc, err := che.GetWorkspacePVC(clientset, namespace, codewind.WorkspaceID)
+		if err != nil {
+			log.Errorf("Unable to create Persistent Volume Claim for PFE: %v\n", err)
+			return err
+		}
+		pvc := generatePVC(codewind, volumeSizeOf(codewind), storageClass, chePvc.GetObjectMeta().GetName(), chePvc.GetObjectMeta().GetUID())
+		_, err = clientset.CoreV1().PersistentVolumeClaims(namespace).Create(&pvc)
+		if err != nil {
+			log.Errorf("Unable to create Persistent Volume Claim for PFE: %v\n", err)
+			return err
+		}
+		rollback.record("persistent volume claim", pvc.GetName(), func() error {
+			return clientset.CoreV1().PersistentVolumeClaims(namespace).Delete(pvc.GetName(), rollbackDeleteOptions())
+		})
+	}
+
+	// Make sure a volume could be provisioned for PFE, rather than having its pod stuck unschedulable
+	if codewind.PVCBindTimeout > 0 {
+		err = WaitForPVCBound(clientset, namespace, codewind.PVCName, codewind.PVCBindTimeout)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // createPFEDeploy creates a Kubernetes deploy for Codewind, marking the Che workspace as its owner
 func createPFEDeploy(codewind Codewind) appsv1.Deployment {
 	labels := map[string]string{
@@ -80,17 +115,40 @@ func createPFEDeploy(codewind Codewind) appsv1.Deployment {
 
 	volumes, volumeMounts := setPFEVolumes(codewind)
 	envVars := setPFEEnvVars(codewind)
+	if securityProfileOf(codewind.PFESecurityProfile) == SecurityProfileRootlessBuildah {
+		// Rootless buildah can't create the mount namespaces of its default OCI isolation
+		envVars = append(envVars, corev1.EnvVar{Name: "BUILDAH_ISOLATION", Value: "chroot"})
+	}
+
+	probeScheme := corev1.URISchemeHTTPS
+	if !pfeServesHTTPS(codewind) {
+		probeScheme = corev1.URISchemeHTTP
+	}
 
-	return generateDeployment(codewind, constants.PFEPrefix, codewind.PFEImage, constants.PFEContainerPort, volumes, volumeMounts, envVars, labels)
+	deploy := generateDeployment(codewind, constants.PFEPrefix, codewind.PFEImage, pfePort(codewind), volumes, volumeMounts, envVars, labels)
+	setSecurityProfile(&deploy.Spec.Template, codewind.PFESecurityProfile, codewind.OnOpenShift)
+	setProbes(&deploy.Spec.Template.Spec.Containers[0], codewind.PFEProbe, pfePort(codewind), probeScheme)
+	deploy.Spec.Template.Spec.Containers[0].Resources = codewind.PFEResources
+	return deploy
 }
 
-// createPFEService creates a Kubernetes service for Codewind, exposing port 9191
+// createPFEService creates a Kubernetes service for Codewind, exposing port 9191 (or port 9090 when PFE serves plain HTTP)
 func createPFEService(codewind Codewind) corev1.Service {
 	labels := map[string]string{
 		"app":               "codewind-pfe",
 		"codewindWorkspace": codewind.WorkspaceID,
 	}
-	return generateService(codewind, constants.PFEPrefix, constants.PFEContainerPort, labels)
+	service := generateService(codewind, constants.PFEPrefix, pfePort(codewind), labels)
+	service.Spec.Type = pfeServiceType(codewind)
+
+	// Some ingress controllers read the protocol of the PFE backend from the service rather than the ingress
+	if exposureOf(codewind) == ExposureIngress {
+		_, annotations := ingressProfileAnnotations(codewind.IngressProfile)
+		if len(annotations) > 0 {
+			service.Annotations = annotations
+		}
+	}
+	return service
 }
 
 func createPerformanceDeploy(codewind Codewind) appsv1.Deployment {
@@ -102,7 +160,11 @@ func createPerformanceDeploy(codewind Codewind) appsv1.Deployment {
 	volumes := []corev1.Volume{}
 	volumeMounts := []corev1.VolumeMount{}
 	envVars := setPerformanceEnvVars(codewind)
-	return generateDeployment(codewind, constants.PerformancePrefix, codewind.PerformanceImage, constants.PerformanceContainerPort, volumes, volumeMounts, envVars, labels)
+	deploy := generateDeployment(codewind, constants.PerformancePrefix, codewind.PerformanceImage, constants.PerformanceContainerPort, volumes, volumeMounts, envVars, labels)
+	setSecurityProfile(&deploy.Spec.Template, codewind.PerformanceSecurityProfile, codewind.OnOpenShift)
+	setProbes(&deploy.Spec.Template.Spec.Containers[0], codewind.PerformanceProbe, constants.PerformanceContainerPort, corev1.URISchemeHTTP)
+	deploy.Spec.Template.Spec.Containers[0].Resources = codewind.PerformanceResources
+	return deploy
 }
 
 func createPerformanceService(codewind Codewind) corev1.Service {
@@ -1,28 +1,44 @@
 package codewind
 
 import (
+	"deploy-pfe/pkg/config"
 	"deploy-pfe/pkg/constants"
 	"fmt"
+	"strconv"
 	"testing"
+
+	v1 "github.com/openshift/api/route/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func setupCodewind() Codewind {
 	cheWorkspaceID := "workspace1erok6723m74axkg"
+	pfeProbe, performanceProbe, _ := GetProbes(config.Default())
+	pfeResources, performanceResources, _ := GetResources(config.Default())
 
 	return Codewind{
-		PFEName:            constants.PFEPrefix + cheWorkspaceID,
-		PFEImage:           constants.PFEImage + ":" + constants.PFEImageTag,
-		PVCName:            constants.PFEPrefix + "-" + cheWorkspaceID,
-		PerformanceName:    constants.PerformancePrefix + cheWorkspaceID,
-		PerformanceImage:   constants.PerformanceImage + ":" + constants.PerformanceTag,
-		Namespace:          "default",
-		WorkspaceID:        cheWorkspaceID,
-		ServiceAccountName: "che-workspace",
-		PullSecret:         "workspace1erok6723m74axkg-registry-secrets",
-		OwnerReferenceName: "codewind",
-		OwnerReferenceUID:  "c22d4a29-ba20-11e9-ac2a-005056a04e5e",
-		Privileged:         true,
-		Ingress:            constants.PFEPrefix + "-" + cheWorkspaceID + "-" + "che.1.2.3.4.nip.io",
+		PFEName:              constants.PFEPrefix + cheWorkspaceID,
+		PFEImage:             constants.PFEImage + ":" + constants.PFEImageTag,
+		PVCName:              constants.PFEPrefix + "-" + cheWorkspaceID,
+		PerformanceName:      constants.PerformancePrefix + cheWorkspaceID,
+		PerformanceImage:     constants.PerformanceImage + ":" + constants.PerformanceTag,
+		Namespace:            "default",
+		WorkspaceID:          cheWorkspaceID,
+		ServiceAccountName:   "che-workspace",
+		PullSecret:           "workspace1erok6723m74axkg-registry-secrets",
+		OwnerReferenceName:   "codewind",
+		OwnerReferenceUID:    "c22d4a29-ba20-11e9-ac2a-005056a04e5e",
+		Ingress:              constants.PFEPrefix + "-" + cheWorkspaceID + "-" + "che.1.2.3.4.nip.io",
+		PFEProbe:             pfeProbe,
+		PerformanceProbe:     performanceProbe,
+		PFEResources:         pfeResources,
+		PerformanceResources: performanceResources,
 	}
 }
 func TestCreatePFEDeployment(t *testing.T) {
@@ -77,6 +93,11 @@ func TestCreatePFEDeployment(t *testing.T) {
 			if pfeContainer.Image != pfeImage {
 				t.Errorf("PFE container using invalid image, had %v, expected %v", pfeContainer.Image, pfeImage)
 			}
+
+			// Verify the image pull secret is set
+			if len(pod.Spec.ImagePullSecrets) != 1 || pod.Spec.ImagePullSecrets[0].Name != tt.codewind.PullSecret {
+				t.Errorf("PFE deployment has image pull secrets %v, expected %v", pod.Spec.ImagePullSecrets, tt.codewind.PullSecret)
+			}
 		})
 	}
 }
@@ -244,6 +265,8 @@ func TestCreatePerformanceService(t *testing.T) {
 // TestVerifyPFEEnvVars verifies that the environment variables passed into Codewind-PFE have the proper values
 func TestVerifyPFEEnvVars(t *testing.T) {
 	codewindInstance := setupCodewind()
+	codewindInstance.OwnerReferenceKind = "PersistentVolumeClaim"
+	codewindInstance.OwnerReferenceAPIVersion = "v1"
 	tests := []struct {
 		name     string
 		codewind Codewind
@@ -300,6 +323,481 @@ func TestVerifyPFEEnvVars(t *testing.T) {
 				continue
 			}
 
+			// Verify that the owner reference passed into the Codewind-PFE container matches the owner of its deployment,
+			// which can be of any kind depending on the ownership strategy
+			ownerReference := pfeDeploy.GetOwnerReferences()[0]
+			if env.Name == "OWNER_REF_KIND" {
+				if env.Value != ownerReference.Kind {
+					t.Errorf("OWNER_REF_KIND doesn't match the kind of the Codewind-PFE owner: %v\n", ownerReference.Kind)
+				}
+				continue
+			}
+			if env.Name == "OWNER_REF_API_VERSION" {
+				if env.Value != ownerReference.APIVersion {
+					t.Errorf("OWNER_REF_API_VERSION doesn't match the API version of the Codewind-PFE owner: %v\n", ownerReference.APIVersion)
+				}
+				continue
+			}
+
 		}
 	}
 }
+
+// TestVerifyProbes verifies that the PFE and Performance dashboard containers are probed on their exposed ports
+func TestVerifyProbes(t *testing.T) {
+	codewindInstance := setupCodewind()
+	tests := []struct {
+		name   string
+		deploy func(Codewind) appsv1.Deployment
+		probe  Probe
+		port   int
+		scheme corev1.URIScheme
+	}{
+		{
+			name:   fmt.Sprintf("Verify PFE probes"),
+			deploy: createPFEDeploy,
+			probe:  codewindInstance.PFEProbe,
+			port:   constants.PFEContainerPort,
+			scheme: corev1.URISchemeHTTPS,
+		},
+		{
+			name:   fmt.Sprintf("Verify Performance dashboard probes"),
+			deploy: createPerformanceDeploy,
+			probe:  codewindInstance.PerformanceProbe,
+			port:   constants.PerformanceContainerPort,
+			scheme: corev1.URISchemeHTTP,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := tt.deploy(codewindInstance).Spec.Template.Spec.Containers[0]
+			probes := map[string]*corev1.Probe{
+				"readiness": container.ReadinessProbe,
+				"liveness":  container.LivenessProbe,
+			}
+			for kind, probe := range probes {
+				if probe == nil || probe.HTTPGet == nil {
+					t.Fatalf("Container %v has no HTTP %v probe", container.Name, kind)
+				}
+				if probe.HTTPGet.Port.IntValue() != tt.port || probe.HTTPGet.Scheme != tt.scheme || probe.HTTPGet.Path != tt.probe.Path {
+					t.Errorf("Container %v %v probe is %v %v:%v, expected %v %v:%v", container.Name, kind, probe.HTTPGet.Scheme, probe.HTTPGet.Path, probe.HTTPGet.Port.IntValue(), tt.scheme, tt.probe.Path, tt.port)
+				}
+				if probe.FailureThreshold != tt.probe.FailureThreshold {
+					t.Errorf("Container %v %v probe failure threshold is %v, expected %v", container.Name, kind, probe.FailureThreshold, tt.probe.FailureThreshold)
+				}
+			}
+			if container.LivenessProbe.InitialDelaySeconds != tt.probe.LivenessDelay {
+				t.Errorf("Container %v liveness probe delay is %v, expected %v", container.Name, container.LivenessProbe.InitialDelaySeconds, tt.probe.LivenessDelay)
+			}
+		})
+	}
+}
+
+// TestPFEProtocol verifies that PFE serves plain HTTP on its HTTP port behind an edge route or HTTPRoute, and HTTPS
+// everywhere else
+func TestPFEProtocol(t *testing.T) {
+	edgeRoute := setupCodewind()
+	edgeRoute.OnOpenShift = true
+	edgeRoute.Route = RouteSettings{Termination: v1.TLSTerminationEdge}
+
+	httpRoute := setupCodewind()
+	httpRoute.Exposure = ExposureGateway
+	httpRoute.Gateway = GatewaySettings{Name: "codewind-gateway", RouteKind: GatewayHTTPRoute}
+
+	passthroughRoute := setupCodewind()
+	passthroughRoute.OnOpenShift = true
+	passthroughRoute.Route = RouteSettings{Termination: v1.TLSTerminationPassthrough}
+
+	tests := []struct {
+		name     string
+		codewind Codewind
+		https    bool
+		port     int
+	}{
+		{
+			name:     fmt.Sprintf("PFE serves HTTPS behind an ingress"),
+			codewind: setupCodewind(),
+			https:    true,
+			port:     constants.PFEContainerPort,
+		},
+		{
+			name:     fmt.Sprintf("PFE serves HTTPS behind a passthrough route"),
+			codewind: passthroughRoute,
+			https:    true,
+			port:     constants.PFEContainerPort,
+		},
+		{
+			name:     fmt.Sprintf("PFE serves HTTP behind an edge route"),
+			codewind: edgeRoute,
+			https:    false,
+			port:     constants.PFEHTTPPort,
+		},
+		{
+			name:     fmt.Sprintf("PFE serves HTTP behind a Gateway API HTTPRoute"),
+			codewind: httpRoute,
+			https:    false,
+			port:     constants.PFEHTTPPort,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := createPFEDeploy(tt.codewind).Spec.Template.Spec.Containers[0]
+			for _, env := range container.Env {
+				if env.Name == "PORTAL_HTTPS" && env.Value != strconv.FormatBool(tt.https) {
+					t.Errorf("PORTAL_HTTPS was %v, expected %v", env.Value, tt.https)
+				}
+			}
+			scheme := corev1.URISchemeHTTPS
+			if !tt.https {
+				scheme = corev1.URISchemeHTTP
+			}
+			if int(container.Ports[0].ContainerPort) != tt.port || container.ReadinessProbe.HTTPGet.Port.IntValue() != tt.port || container.ReadinessProbe.HTTPGet.Scheme != scheme {
+				t.Errorf("PFE container serves on port %v and is probed over %v on port %v, expected %v on port %v", container.Ports[0].ContainerPort, container.ReadinessProbe.HTTPGet.Scheme, container.ReadinessProbe.HTTPGet.Port.IntValue(), scheme, tt.port)
+			}
+			service := createPFEService(tt.codewind)
+			if int(service.Spec.Ports[0].Port) != tt.port || service.Spec.Ports[0].TargetPort.IntValue() != tt.port {
+				t.Errorf("PFE service exposes port %v to %v, expected port %v", service.Spec.Ports[0].Port, service.Spec.Ports[0].TargetPort.IntValue(), tt.port)
+			}
+		})
+	}
+}
+
+// TestGetProbes verifies that the probe settings can be overridden, and are validated
+func TestGetProbes(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(settings *config.Config)
+		valid     bool
+		pfePath   string
+	}{
+		{
+			name: fmt.Sprintf("Override the PFE probe path and period"),
+			configure: func(settings *config.Config) {
+				settings.PFE.Probe.Path = "/health"
+				settings.PFE.Probe.Period = 30
+			},
+			valid:   true,
+			pfePath: "/health",
+		},
+		{
+			name:      fmt.Sprintf("Reject a negative probe delay"),
+			configure: func(settings *config.Config) { settings.PFE.Probe.LivenessDelay = -1 },
+			valid:     false,
+		},
+		{
+			name:      fmt.Sprintf("Reject a failure threshold of zero"),
+			configure: func(settings *config.Config) { settings.Performance.Probe.FailureThreshold = 0 },
+			valid:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.Default()
+			tt.configure(settings)
+
+			pfeProbe, _, err := GetProbes(settings)
+			if tt.valid && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("Invalid probe settings %+v weren't rejected", settings.PFE.Probe)
+				}
+				return
+			}
+			if pfeProbe.Path != tt.pfePath {
+				t.Errorf("PFE probe path is %v, expected %v", pfeProbe.Path, tt.pfePath)
+			}
+		})
+	}
+}
+
+// TestGetImagePullPolicy verifies that the pull policy of the Codewind containers can be overridden, and is validated
+func TestGetImagePullPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting string
+		valid   bool
+		policy  corev1.PullPolicy
+	}{
+		{
+			name:    fmt.Sprintf("Default an empty pull policy"),
+			setting: "",
+			valid:   true,
+			policy:  constants.ImagePullPolicy,
+		},
+		{
+			name:    fmt.Sprintf("Override the pull policy"),
+			setting: "IfNotPresent",
+			valid:   true,
+			policy:  corev1.PullIfNotPresent,
+		},
+		{
+			name:    fmt.Sprintf("Reject an unknown pull policy"),
+			setting: "always",
+			valid:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.Default()
+			settings.ImagePullPolicy = tt.setting
+
+			policy, err := GetImagePullPolicy(settings)
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("Invalid pull policy %q wasn't rejected", tt.setting)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if policy != tt.policy {
+				t.Errorf("Pull policy is %v, expected %v", policy, tt.policy)
+			}
+
+			codewindInstance := setupCodewind()
+			codewindInstance.ImagePullPolicy = policy
+			deploy := createPFEDeploy(codewindInstance)
+			if actual := deploy.Spec.Template.Spec.Containers[0].ImagePullPolicy; actual != tt.policy {
+				t.Errorf("PFE container pull policy is %v, expected %v", actual, tt.policy)
+			}
+		})
+	}
+}
+
+// TestGetResources verifies that the container requests and limits can be overridden, and are validated
+func TestGetResources(t *testing.T) {
+	tests := []struct {
+		name           string
+		configure      func(settings *config.Config)
+		valid          bool
+		pfeMemoryLimit string
+	}{
+		{
+			name:           fmt.Sprintf("Default requests and limits are valid"),
+			configure:      func(settings *config.Config) {},
+			valid:          true,
+			pfeMemoryLimit: constants.PFEMemoryLimit,
+		},
+		{
+			name:           fmt.Sprintf("Override the PFE memory limit"),
+			configure:      func(settings *config.Config) { settings.PFE.Resources.MemoryLimit = "8Gi" },
+			valid:          true,
+			pfeMemoryLimit: "8Gi",
+		},
+		{
+			name:      fmt.Sprintf("Reject an invalid quantity"),
+			configure: func(settings *config.Config) { settings.Performance.Resources.CPULimit = "two" },
+			valid:     false,
+		},
+		{
+			name:      fmt.Sprintf("Reject a request that exceeds its limit"),
+			configure: func(settings *config.Config) { settings.PFE.Resources.MemoryRequest = "6Gi" },
+			valid:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.Default()
+			tt.configure(settings)
+
+			pfeResources, _, err := GetResources(settings)
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("Invalid resource settings %+v weren't rejected", settings.PFE.Resources)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			memoryLimit := pfeResources.Limits[corev1.ResourceMemory]
+			if memoryLimit.String() != tt.pfeMemoryLimit {
+				t.Errorf("PFE memory limit is %v, expected %v", memoryLimit.String(), tt.pfeMemoryLimit)
+			}
+
+			// Verify the requests and limits are set on the PFE container
+			codewindInstance := setupCodewind()
+			codewindInstance.PFEResources = pfeResources
+			container := createPFEDeploy(codewindInstance).Spec.Template.Spec.Containers[0]
+			if _, ok := container.Resources.Limits[corev1.ResourceCPU]; !ok {
+				t.Errorf("PFE container has no CPU limit")
+			}
+		})
+	}
+}
+
+// setupWorkspacePVC returns the PVC of the Che workspace, that the Codewind PVC is tied to
+func setupWorkspacePVC(codewind Codewind) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "claim-che-workspace",
+			Namespace: codewind.Namespace,
+			UID:       "6f1f4f02-ba20-11e9-ac2a-005056a04e5e",
+			Labels: map[string]string{
+				"che.workspace_id": codewind.WorkspaceID,
+			},
+		},
+	}
+}
+
+// setupStorageClass returns an NFS storage class, that supports the ReadWriteMany volume Codewind needs
+func setupStorageClass() *storagev1.StorageClass {
+	return &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "nfs-client",
+		},
+		Provisioner: "cluster.local/nfs-subdir-external-provisioner",
+	}
+}
+
+// countActions returns the number of actions with the given verb that the fake clientset has seen
+func countActions(clientset *fake.Clientset, verb string) int {
+	count := 0
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == verb {
+			count++
+		}
+	}
+	return count
+}
+
+// TestDeployCodewind verifies a full deploy of Codewind, followed by re-deploys with and without any changes
+func TestDeployCodewind(t *testing.T) {
+	codewindInstance := setupCodewind()
+	clientset := fake.NewSimpleClientset(setupWorkspacePVC(codewindInstance), setupStorageClass())
+
+	// Deploy Codewind into an empty namespace
+	err := DeployCodewind(clientset, codewindInstance, codewindInstance.Namespace, nil)
+	if err != nil {
+		t.Fatalf("Initial deploy failed: %v", err)
+	}
+	if creates := countActions(clientset, "create"); creates != 5 {
+		t.Errorf("Initial deploy created %v objects, expected %v", creates, 5)
+	}
+	pvc, err := clientset.CoreV1().PersistentVolumeClaims(codewindInstance.Namespace).Get(codewindInstance.PVCName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("PFE PVC was not created: %v", err)
+	}
+	if pvc.GetOwnerReferences()[0].Name != "claim-che-workspace" {
+		t.Errorf("PFE PVC is owned by %v, expected the workspace PVC", pvc.GetOwnerReferences()[0].Name)
+	}
+	for _, name := range []string{constants.PFEPrefix + "-" + codewindInstance.WorkspaceID, constants.PerformancePrefix + "-" + codewindInstance.WorkspaceID} {
+		if _, err := clientset.AppsV1().Deployments(codewindInstance.Namespace).Get(name, metav1.GetOptions{}); err != nil {
+			t.Errorf("Deployment %v was not created: %v", name, err)
+		}
+		if _, err := clientset.CoreV1().Services(codewindInstance.Namespace).Get(name, metav1.GetOptions{}); err != nil {
+			t.Errorf("Service %v was not created: %v", name, err)
+		}
+	}
+
+	// Re-deploying the same instance, such as after a workspace restart, should leave everything alone
+	clientset.ClearActions()
+	err = DeployCodewind(clientset, codewindInstance, codewindInstance.Namespace, nil)
+	if err != nil {
+		t.Fatalf("Re-deploy failed: %v", err)
+	}
+	if creates, updates := countActions(clientset, "create"), countActions(clientset, "update"); creates != 0 || updates != 0 {
+		t.Errorf("Unchanged re-deploy created %v and updated %v objects, expected none", creates, updates)
+	}
+
+	// Re-deploying with a new PFE image, such as after a sidecar update, should only update the PFE deployment
+	clientset.ClearActions()
+	codewindInstance.PFEImage = constants.PFEImage + ":0.9.0"
+	err = DeployCodewind(clientset, codewindInstance, codewindInstance.Namespace, nil)
+	if err != nil {
+		t.Fatalf("Re-deploy with a new image failed: %v", err)
+	}
+	if updates := countActions(clientset, "update"); updates != 1 {
+		t.Errorf("Re-deploy with a new image updated %v objects, expected %v", updates, 1)
+	}
+	deploy, _ := clientset.AppsV1().Deployments(codewindInstance.Namespace).Get(constants.PFEPrefix+"-"+codewindInstance.WorkspaceID, metav1.GetOptions{})
+	if deploy.Spec.Template.Spec.Containers[0].Image != codewindInstance.PFEImage {
+		t.Errorf("PFE deployment uses image %v, expected %v", deploy.Spec.Template.Spec.Containers[0].Image, codewindInstance.PFEImage)
+	}
+}
+
+// TestDeployCodewindFailure verifies that a failure to create a resource is returned
+func TestDeployCodewindFailure(t *testing.T) {
+	codewindInstance := setupCodewind()
+	clientset := fake.NewSimpleClientset(setupWorkspacePVC(codewindInstance), setupStorageClass())
+	clientset.PrependReactor("create", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("deployments.apps is forbidden")
+	})
+
+	err := DeployCodewind(clientset, codewindInstance, codewindInstance.Namespace, nil)
+	if err == nil {
+		t.Fatalf("Deploy didn't fail when the PFE deployment couldn't be created")
+	}
+	if _, err := clientset.CoreV1().Services(codewindInstance.Namespace).Get(constants.PerformancePrefix+"-"+codewindInstance.WorkspaceID, metav1.GetOptions{}); err == nil {
+		t.Errorf("Deploy continued after the PFE deployment couldn't be created")
+	}
+}
+
+// TestDeployCodewindWithoutWorkspacePVC verifies that Codewind isn't deployed when the workspace PVC can't be found
+func TestDeployCodewindWithoutWorkspacePVC(t *testing.T) {
+	codewindInstance := setupCodewind()
+	clientset := fake.NewSimpleClientset()
+
+	err := DeployCodewind(clientset, codewindInstance, codewindInstance.Namespace, nil)
+	if err == nil {
+		t.Fatalf("Deploy didn't fail when the workspace PVC couldn't be found")
+	}
+	if creates := countActions(clientset, "create"); creates != 0 {
+		t.Errorf("Deploy created %v objects, expected none", creates)
+	}
+}
+
+// TestOwnerReferences verifies that the owner of the workspace is used for every generated object, and that no owner
+// references are set when the workspace has no owner
+func TestOwnerReferences(t *testing.T) {
+	withoutOwner := setupCodewind()
+	withoutOwner.OwnerReferenceName = ""
+	withoutOwner.OwnerReferenceUID = ""
+	withoutOwner.OwnerReferenceKind = ""
+	withoutOwner.OwnerReferenceAPIVersion = ""
+
+	tests := []struct {
+		name     string
+		codewind Codewind
+	}{
+		{
+			name:     fmt.Sprintf("Objects are owned by the workspace Deployment"),
+			codewind: setupCodewind(),
+		},
+		{
+			name:     fmt.Sprintf("Objects have no owner if the workspace has none"),
+			codewind: withoutOwner,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pfeDeploy := createPFEDeploy(tt.codewind)
+			pfeService := createPFEService(tt.codewind)
+			route := CreateRoute(tt.codewind)
+			ingress := CreateIngress(tt.codewind)
+			objects := map[string][]metav1.OwnerReference{
+				"deployment": pfeDeploy.GetOwnerReferences(),
+				"service":    pfeService.GetOwnerReferences(),
+				"route":      route.GetOwnerReferences(),
+				"ingress":    ingress.GetOwnerReferences(),
+			}
+			for kind, ownerReferences := range objects {
+				if tt.codewind.OwnerReferenceUID == "" {
+					if len(ownerReferences) != 0 {
+						t.Errorf("PFE %v has owner references %v, expected none", kind, ownerReferences)
+					}
+					continue
+				}
+				if len(ownerReferences) != 1 {
+					t.Fatalf("PFE %v has %v owner references, expected %v", kind, len(ownerReferences), 1)
+				}
+				if ownerReferences[0].Kind != tt.codewind.OwnerReferenceKind || ownerReferences[0].APIVersion != tt.codewind.OwnerReferenceAPIVersion {
+					t.Errorf("PFE %v is owned by a %v %v, expected a %v %v", kind, ownerReferences[0].APIVersion, ownerReferences[0].Kind, tt.codewind.OwnerReferenceAPIVersion, tt.codewind.OwnerReferenceKind)
+				}
+			}
+		})
+	}
+}
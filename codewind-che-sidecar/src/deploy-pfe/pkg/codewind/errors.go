@@ -0,0 +1,24 @@
+package codewind
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PVCNotBoundError is returned when the PFE persistent volume claim isn't bound to a volume before the timeout, such as
+// when no volume can be provisioned for it. Events holds the warnings that Kubernetes reported for the claim
+type PVCNotBoundError struct {
+	Name    string
+	Phase   string
+	Timeout time.Duration
+	Events  []string
+}
+
+func (e *PVCNotBoundError) Error() string {
+	message := fmt.Sprintf("persistent volume claim %s was still %s after %v", e.Name, e.Phase, e.Timeout)
+	if len(e.Events) == 0 {
+		return message + ", no events were reported for it"
+	}
+	return message + ": " + strings.Join(e.Events, "; ")
+}
@@ -0,0 +1,102 @@
+package codewind
+
+import (
+	"fmt"
+
+	"deploy-pfe/pkg/config"
+	"deploy-pfe/pkg/constants"
+
+	corev1 "k8s.io/api/core/v1"
+
+	v1 "github.com/openshift/api/route/v1"
+)
+
+// ExposureStrategy determines how Codewind is exposed outside of the cluster
+type ExposureStrategy string
+
+const (
+	// ExposureRoute exposes Codewind through an OpenShift route
+	ExposureRoute ExposureStrategy = "route"
+
+	// ExposureIngress exposes Codewind through a Kubernetes ingress
+	ExposureIngress ExposureStrategy = "ingress"
+
+	// ExposureGateway exposes Codewind through a Gateway API TLSRoute or HTTPRoute, attached to an existing Gateway
+	ExposureGateway ExposureStrategy = "gateway"
+
+	// ExposureNone keeps Codewind internal to the cluster, only reachable through its service (such as by the sidecar's
+	// proxy in the Che workspace)
+	ExposureNone ExposureStrategy = "none"
+
+	// ExposureNodePort exposes Codewind through a NodePort service, on a port of every node of the cluster
+	ExposureNodePort ExposureStrategy = "nodeport"
+
+	// ExposureLoadBalancer exposes Codewind through a LoadBalancer service, on an address assigned by the cloud provider
+	ExposureLoadBalancer ExposureStrategy = "loadbalancer"
+)
+
+// GetExposureStrategy returns the exposure strategy that was set (route, ingress, gateway, none, nodeport or
+// loadbalancer). If it isn't set, Codewind is exposed through a route on OpenShift, and through an ingress everywhere else
+func GetExposureStrategy(settings *config.Config, onOpenShift bool) (ExposureStrategy, error) {
+	strategy := ExposureStrategy(settings.Exposure)
+	switch strategy {
+	case "":
+		return defaultExposure(onOpenShift), nil
+	case ExposureRoute:
+		if !onOpenShift {
+			return "", fmt.Errorf("exposure strategy %q is only available on OpenShift", strategy)
+		}
+		return strategy, nil
+	case ExposureIngress, ExposureGateway, ExposureNone, ExposureNodePort, ExposureLoadBalancer:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("invalid exposure strategy %q, expected %s, %s, %s, %s, %s or %s", strategy,
+		ExposureRoute, ExposureIngress, ExposureGateway, ExposureNone, ExposureNodePort, ExposureLoadBalancer)
+}
+
+// exposureOf returns the exposure strategy of a Codewind instance, defaulting it from whether it runs on OpenShift
+func exposureOf(codewind Codewind) ExposureStrategy {
+	if codewind.Exposure == "" {
+		return defaultExposure(codewind.OnOpenShift)
+	}
+	return codewind.Exposure
+}
+
+// defaultExposure returns the exposure strategy used when $CODEWIND_EXPOSURE isn't set
+func defaultExposure(onOpenShift bool) ExposureStrategy {
+	if onOpenShift {
+		return ExposureRoute
+	}
+	return ExposureIngress
+}
+
+// pfeServesHTTPS returns whether PFE terminates TLS itself. It serves plain HTTP instead when TLS is terminated in front
+// of it, by an edge route or by a Gateway through an HTTPRoute
+func pfeServesHTTPS(codewind Codewind) bool {
+	switch exposureOf(codewind) {
+	case ExposureRoute:
+		return codewind.Route.Termination != v1.TLSTerminationEdge
+	case ExposureGateway:
+		return codewind.Gateway.RouteKind != GatewayHTTPRoute
+	}
+	return true
+}
+
+// pfePort returns the port that PFE serves on: its HTTPS port, or its HTTP port when it serves plain HTTP
+func pfePort(codewind Codewind) int {
+	if pfeServesHTTPS(codewind) {
+		return constants.PFEContainerPort
+	}
+	return constants.PFEHTTPPort
+}
+
+// pfeServiceType returns the type of the PFE service for the exposure strategy of a Codewind instance
+func pfeServiceType(codewind Codewind) corev1.ServiceType {
+	switch exposureOf(codewind) {
+	case ExposureNodePort:
+		return corev1.ServiceTypeNodePort
+	case ExposureLoadBalancer:
+		return corev1.ServiceTypeLoadBalancer
+	}
+	return corev1.ServiceTypeClusterIP
+}
@@ -0,0 +1,136 @@
+package codewind
+
+import (
+	"fmt"
+
+	"deploy-pfe/pkg/config"
+	"deploy-pfe/pkg/constants"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Kinds of Gateway API routes that Codewind can be exposed through
+const (
+	// GatewayTLSRoute passes TLS through the Gateway to PFE's HTTPS port, so PFE terminates TLS itself
+	GatewayTLSRoute = "TLSRoute"
+
+	// GatewayHTTPRoute has the Gateway terminate TLS, and connect to PFE's HTTP port, as PFE then serves plain HTTP
+	GatewayHTTPRoute = "HTTPRoute"
+)
+
+// gatewayRouteAPIVersions are the API versions that can serve each kind of Gateway API route, from newest to oldest
+var gatewayRouteAPIVersions = map[string][]string{
+	GatewayTLSRoute:  {"gateway.networking.k8s.io/v1alpha2"},
+	GatewayHTTPRoute: {"gateway.networking.k8s.io/v1", "gateway.networking.k8s.io/v1beta1"},
+}
+
+// gatewayRouteResources are the resource names of each kind of Gateway API route
+var gatewayRouteResources = map[string]string{
+	GatewayTLSRoute:  "tlsroutes",
+	GatewayHTTPRoute: "httproutes",
+}
+
+// GatewaySettings represents the Gateway that Codewind is attached to, and the kind of route attaching it
+type GatewaySettings struct {
+	Name       string
+	Namespace  string
+	Listener   string
+	RouteKind  string
+	APIVersion string
+}
+
+// GetGatewaySettings returns the Gateway settings: the name and namespace of the Gateway to attach to (in the Codewind
+// namespace if no namespace is set), the listener of the Gateway to attach to (all listeners if not set) and the kind
+// of route attaching it (TLSRoute or HTTPRoute)
+func GetGatewaySettings(settings *config.Config) (GatewaySettings, error) {
+	gateway := GatewaySettings{
+		Name:      settings.Gateway.Name,
+		Namespace: settings.Gateway.Namespace,
+		Listener:  settings.Gateway.Listener,
+		RouteKind: settings.Gateway.RouteKind,
+	}
+	if gateway.Name == "" {
+		return GatewaySettings{}, fmt.Errorf("gateway.name must be set to expose Codewind through a Gateway")
+	}
+	if gateway.RouteKind == "" {
+		gateway.RouteKind = constants.GatewayRouteKind
+	}
+	if _, ok := gatewayRouteResources[gateway.RouteKind]; !ok {
+		return GatewaySettings{}, fmt.Errorf("invalid route kind %q for gateway.routeKind, expected %s or %s", gateway.RouteKind, GatewayTLSRoute, GatewayHTTPRoute)
+	}
+	return gateway, nil
+}
+
+// GatewayRouteAPIVersions returns the API versions that can serve the given kind of Gateway API route, and its
+// resource name, to detect which one is served by the cluster
+func GatewayRouteAPIVersions(kind string) ([]string, string) {
+	return gatewayRouteAPIVersions[kind], gatewayRouteResources[kind]
+}
+
+// CreateGatewayRoute returns a Gateway API TLSRoute or HTTPRoute for the Codewind PFE service, attached to the
+// configured Gateway. Like ingresses, Gateway API routes are built as unstructured objects
+func CreateGatewayRoute(codewind Codewind) *unstructured.Unstructured {
+	labels := map[string]string{
+		"app":               constants.PFEPrefix,
+		"codewindWorkspace": codewind.WorkspaceID,
+	}
+
+	kind := codewind.Gateway.RouteKind
+	if kind == "" {
+		kind = constants.GatewayRouteKind
+	}
+	apiVersion := codewind.Gateway.APIVersion
+	if apiVersion == "" {
+		apiVersion = gatewayRouteAPIVersions[kind][0]
+	}
+
+	parentRef := map[string]interface{}{
+		"name": codewind.Gateway.Name,
+	}
+	if codewind.Gateway.Namespace != "" {
+		parentRef["namespace"] = codewind.Gateway.Namespace
+	}
+	if codewind.Gateway.Listener != "" {
+		parentRef["sectionName"] = codewind.Gateway.Listener
+	}
+
+	route := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"spec": map[string]interface{}{
+				"parentRefs": []interface{}{parentRef},
+				"hostnames":  []interface{}{codewind.Ingress},
+				"rules": []interface{}{
+					map[string]interface{}{
+						"backendRefs": []interface{}{
+							map[string]interface{}{
+								"name": constants.PFEPrefix + "-" + codewind.WorkspaceID,
+								"port": int64(pfePort(codewind)),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	route.SetName(constants.PFEPrefix + "-" + codewind.WorkspaceID)
+	route.SetNamespace(codewind.Namespace)
+	route.SetLabels(labels)
+	route.SetOwnerReferences(ownerReferences(codewind))
+	return route
+}
+
+// ReconcileGatewayRoute creates the Codewind Gateway API route in the given namespace, or updates the existing one if
+// it has drifted
+func ReconcileGatewayRoute(dynamicClient dynamic.Interface, route *unstructured.Unstructured, namespace string, rollback *Rollback) error {
+	return reconcileUnstructured(dynamicClient.Resource(gatewayRouteResource(route.GetAPIVersion(), route.GetKind())).Namespace(namespace), route, rollback)
+}
+
+// gatewayRouteResource returns the resource of the given kind of Gateway API route
+func gatewayRouteResource(apiVersion string, kind string) schema.GroupVersionResource {
+	gv, _ := schema.ParseGroupVersion(apiVersion)
+	return gv.WithResource(gatewayRouteResources[kind])
+}
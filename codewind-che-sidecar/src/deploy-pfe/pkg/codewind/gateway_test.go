@@ -0,0 +1,142 @@
+package codewind
+
+import (
+	"fmt"
+	"testing"
+
+	"deploy-pfe/pkg/config"
+	"deploy-pfe/pkg/constants"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCreateGatewayRoute(t *testing.T) {
+	tests := []struct {
+		name       string
+		gateway    GatewaySettings
+		apiVersion string
+		parentRef  map[string]interface{}
+		port       int64
+	}{
+		{
+			name:       fmt.Sprintf("TLSRoute attached to a Gateway in the Codewind namespace"),
+			gateway:    GatewaySettings{Name: "codewind-gateway"},
+			apiVersion: "gateway.networking.k8s.io/v1alpha2",
+			parentRef:  map[string]interface{}{"name": "codewind-gateway"},
+			port:       constants.PFEContainerPort,
+		},
+		{
+			name:       fmt.Sprintf("HTTPRoute attached to a listener of a Gateway in another namespace"),
+			gateway:    GatewaySettings{Name: "shared", Namespace: "gateways", Listener: "https", RouteKind: GatewayHTTPRoute, APIVersion: "gateway.networking.k8s.io/v1"},
+			apiVersion: "gateway.networking.k8s.io/v1",
+			parentRef:  map[string]interface{}{"name": "shared", "namespace": "gateways", "sectionName": "https"},
+			port:       constants.PFEHTTPPort,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codewindInstance := setupCodewind()
+			codewindInstance.Exposure = ExposureGateway
+			codewindInstance.Gateway = tt.gateway
+			route := CreateGatewayRoute(codewindInstance)
+
+			kind := tt.gateway.RouteKind
+			if kind == "" {
+				kind = GatewayTLSRoute
+			}
+			if route.GetKind() != kind || route.GetAPIVersion() != tt.apiVersion {
+				t.Errorf("Gateway route was a %s %s, expected a %s %s", route.GetAPIVersion(), route.GetKind(), tt.apiVersion, kind)
+			}
+			parentRefs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+			if fmt.Sprint(parentRefs) != fmt.Sprint([]interface{}{tt.parentRef}) {
+				t.Errorf("Gateway route parents were %v, expected %v", parentRefs, tt.parentRef)
+			}
+			// TLSRoutes pass TLS through to PFE's HTTPS port, while HTTPRoutes connect to its plain HTTP port
+			rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+			backendRefs, _, _ := unstructured.NestedSlice(rules[0].(map[string]interface{}), "backendRefs")
+			if port := backendRefs[0].(map[string]interface{})["port"]; port != tt.port {
+				t.Errorf("Gateway route backend port was %v, expected %v", port, tt.port)
+			}
+			hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+			if len(hostnames) != 1 || hostnames[0] != codewindInstance.Ingress {
+				t.Errorf("Gateway route hostnames were %v, expected %s", hostnames, codewindInstance.Ingress)
+			}
+
+			// The route carries the same labels and owner references as the ingress
+			ingress := CreateIngress(codewindInstance)
+			if !equality.Semantic.DeepEqual(route.GetLabels(), ingress.GetLabels()) {
+				t.Errorf("Gateway route labels were %v, expected %v", route.GetLabels(), ingress.GetLabels())
+			}
+			if !equality.Semantic.DeepEqual(route.GetOwnerReferences(), ingress.GetOwnerReferences()) {
+				t.Errorf("Gateway route owner references were %v, expected %v", route.GetOwnerReferences(), ingress.GetOwnerReferences())
+			}
+		})
+	}
+}
+
+func TestGetExposureStrategy(t *testing.T) {
+	tests := []struct {
+		name        string
+		exposure    string
+		gatewayName string
+		onOpenShift bool
+		want        ExposureStrategy
+		wantErr     bool
+	}{
+		{
+			name: fmt.Sprintf("Default to an ingress on Kubernetes"),
+			want: ExposureIngress,
+		},
+		{
+			name:        fmt.Sprintf("Default to a route on OpenShift"),
+			onOpenShift: true,
+			want:        ExposureRoute,
+		},
+		{
+			name:        fmt.Sprintf("Gateway on OpenShift"),
+			exposure:    "gateway",
+			gatewayName: "codewind-gateway",
+			onOpenShift: true,
+			want:        ExposureGateway,
+		},
+		{
+			name:     fmt.Sprintf("Gateway without a Gateway name"),
+			exposure: "gateway",
+			wantErr:  true,
+		},
+		{
+			name:     fmt.Sprintf("Route on Kubernetes"),
+			exposure: "route",
+			wantErr:  true,
+		},
+		{
+			name:     fmt.Sprintf("Internal only"),
+			exposure: "none",
+			want:     ExposureNone,
+		},
+		{
+			name:     fmt.Sprintf("Invalid exposure strategy"),
+			exposure: "service-mesh",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.Default()
+			settings.Exposure = tt.exposure
+			settings.Gateway.Name = tt.gatewayName
+
+			exposure, err := GetExposureStrategy(settings, tt.onOpenShift)
+			if err == nil && exposure == ExposureGateway {
+				_, err = GetGatewaySettings(settings)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !tt.wantErr && exposure != tt.want {
+				t.Errorf("Exposure strategy was %s, expected %s", exposure, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package codewind
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+
+	log "github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// hostnameHashLength is the number of hex characters of the hash suffix added to DNS labels that had to be truncated
+const hostnameHashLength = 8
+
+// HostnameData is the data that the hostname template of Codewind is executed with
+type HostnameData struct {
+	Prefix      string
+	WorkspaceID string
+	Namespace   string
+	CheDomain   string
+}
+
+// GenerateHostname executes the hostname template with the given data, such as
+// `{{.Prefix}}-{{.WorkspaceID}}.{{.CheDomain}}`. DNS labels longer than 63 characters are truncated, with a hash of
+// the full label as a suffix so that truncated hostnames stay unique. An error is returned if the template is invalid,
+// or the hostname isn't a valid RFC 1123 DNS name
+func GenerateHostname(hostnameTemplate string, data HostnameData) (string, error) {
+	tmpl, err := template.New("hostname").Option("missingkey=error").Parse(hostnameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid hostname template %q: %v", hostnameTemplate, err)
+	}
+	var b bytes.Buffer
+	err = tmpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("invalid hostname template %q: %v", hostnameTemplate, err)
+	}
+
+	labels := strings.Split(strings.ToLower(b.String()), ".")
+	for i, label := range labels {
+		if len(label) > validation.DNS1123LabelMaxLength {
+			labels[i] = truncateLabel(label)
+			log.Infof("Truncated DNS label %s of the Codewind hostname to %s\n", label, labels[i])
+		}
+	}
+	hostname := strings.Join(labels, ".")
+
+	if errs := validation.IsDNS1123Subdomain(hostname); len(errs) > 0 {
+		return "", fmt.Errorf("invalid hostname %q generated from template %q: %s", hostname, hostnameTemplate, strings.Join(errs, ", "))
+	}
+	for _, label := range labels {
+		if errs := validation.IsDNS1123Label(label); len(errs) > 0 {
+			return "", fmt.Errorf("invalid hostname %q generated from template %q, label %q: %s", hostname, hostnameTemplate, label, strings.Join(errs, ", "))
+		}
+	}
+	return hostname, nil
+}
+
+// truncateLabel shortens a DNS label to the maximum label length, replacing its end with a hash of the full label
+func truncateLabel(label string) string {
+	hash := sha256.Sum256([]byte(label))
+	prefix := label[:validation.DNS1123LabelMaxLength-hostnameHashLength-1]
+	return strings.TrimRight(prefix, "-") + "-" + hex.EncodeToString(hash[:])[:hostnameHashLength]
+}
@@ -0,0 +1,98 @@
+package codewind
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"deploy-pfe/pkg/constants"
+)
+
+func TestGenerateHostname(t *testing.T) {
+	data := HostnameData{
+		Prefix:      constants.PFEPrefix,
+		WorkspaceID: "workspace1erok6723m74axkg",
+		Namespace:   "che",
+		CheDomain:   "che.1.2.3.4.nip.io",
+	}
+	longData := data
+	longData.WorkspaceID = "Workspace" + strings.Repeat("a", 60)
+
+	tests := []struct {
+		name     string
+		template string
+		data     HostnameData
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     fmt.Sprintf("Default template"),
+			template: constants.HostnameTemplate,
+			data:     data,
+			want:     "codewind-workspace1erok6723m74axkg-che.1.2.3.4.nip.io",
+		},
+		{
+			name:     fmt.Sprintf("Template with a subdomain per workspace"),
+			template: "{{.Prefix}}-{{.WorkspaceID}}.{{.Namespace}}.{{.CheDomain}}",
+			data:     data,
+			want:     "codewind-workspace1erok6723m74axkg.che.che.1.2.3.4.nip.io",
+		},
+		{
+			name:     fmt.Sprintf("Over-long label is truncated with a hash suffix, and lowercased"),
+			template: "{{.Prefix}}-{{.WorkspaceID}}.{{.CheDomain}}",
+			data:     longData,
+		},
+		{
+			name:     fmt.Sprintf("Invalid characters"),
+			template: "{{.Prefix}}_{{.WorkspaceID}}.{{.CheDomain}}",
+			data:     data,
+			wantErr:  true,
+		},
+		{
+			name:     fmt.Sprintf("Empty label"),
+			template: "{{.Prefix}}..{{.CheDomain}}",
+			data:     data,
+			wantErr:  true,
+		},
+		{
+			name:     fmt.Sprintf("Unknown template field"),
+			template: "{{.Team}}.{{.CheDomain}}",
+			data:     data,
+			wantErr:  true,
+		},
+		{
+			name:     fmt.Sprintf("Unparseable template"),
+			template: "{{.Prefix",
+			data:     data,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hostname, err := GenerateHostname(tt.template, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.want != "" && hostname != tt.want {
+				t.Errorf("Hostname was %s, expected %s", hostname, tt.want)
+			}
+			if !tt.wantErr {
+				for _, label := range strings.Split(hostname, ".") {
+					if len(label) > 63 {
+						t.Errorf("Hostname %s has a label longer than 63 characters", hostname)
+					}
+				}
+			}
+		})
+	}
+
+	// Truncated labels stay unique, and are stable across runs
+	otherData := longData
+	otherData.WorkspaceID += "b"
+	first, _ := GenerateHostname("{{.WorkspaceID}}", longData)
+	second, _ := GenerateHostname("{{.WorkspaceID}}", otherData)
+	again, _ := GenerateHostname("{{.WorkspaceID}}", longData)
+	if first == second || first != again || !strings.HasPrefix(first, "workspaceaaa") {
+		t.Errorf("Truncated hostnames %s and %s aren't unique and stable", first, second)
+	}
+}
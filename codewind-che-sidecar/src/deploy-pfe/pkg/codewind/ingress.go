@@ -0,0 +1,114 @@
+package codewind
+
+import (
+	"deploy-pfe/pkg/constants"
+
+	log "github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// CreateIngress returns a Kubernetes ingress for the Codewind PFE service, for the ingress API version that's available
+// on the cluster (networking.k8s.io/v1, networking.k8s.io/v1beta1 or extensions/v1beta1).
+// The ingress is built as an unstructured object, as the Kubernetes API types we build against predate networking.k8s.io/v1
+func CreateIngress(codewind Codewind) *unstructured.Unstructured {
+	labels := map[string]string{
+		"app":               constants.PFEPrefix,
+		"codewindWorkspace": codewind.WorkspaceID,
+	}
+
+	// The annotations of the ingress profile, with any extra annotations that were configured on top
+	annotations, _ := ingressProfileAnnotations(codewind.IngressProfile)
+	for key, value := range codewind.IngressAnnotations {
+		annotations[key] = value
+	}
+
+	apiVersion := codewind.IngressAPIVersion
+	if apiVersion == "" {
+		apiVersion = constants.IngressAPIVersion
+	}
+	serviceName := constants.PFEPrefix + "-" + codewind.WorkspaceID
+
+	// networking.k8s.io/v1 moved the service name and port into a nested backend, and requires a path type
+	path := map[string]interface{}{
+		"path": "/",
+	}
+	if apiVersion == "networking.k8s.io/v1" {
+		path["pathType"] = "Prefix"
+		path["backend"] = map[string]interface{}{
+			"service": map[string]interface{}{
+				"name": serviceName,
+				"port": map[string]interface{}{
+					"number": int64(constants.PFEContainerPort),
+				},
+			},
+		}
+	} else {
+		path["backend"] = map[string]interface{}{
+			"serviceName": serviceName,
+			"servicePort": int64(constants.PFEContainerPort),
+		}
+	}
+
+	spec := map[string]interface{}{
+		"rules": []interface{}{
+			map[string]interface{}{
+				"host": codewind.Ingress,
+				"http": map[string]interface{}{
+					"paths": []interface{}{path},
+				},
+			},
+		},
+	}
+
+	// extensions/v1beta1 predates the ingressClassName field, so the ingress class has to be set as an annotation instead.
+	// The API server rejects ingresses with both, so an ingress class annotation set by the ingress profile (such as
+	// gce) or the extra annotations takes the place of the ingressClassName field
+	if classAnnotation, ok := annotations["kubernetes.io/ingress.class"]; ok && codewind.IngressClass != "" && apiVersion != "extensions/v1beta1" {
+		if classAnnotation != codewind.IngressClass {
+			log.Warnf("Ingress class %s is ignored, as the ingress is selected by its kubernetes.io/ingress.class annotation %s\n", codewind.IngressClass, classAnnotation)
+		}
+	} else if codewind.IngressClass != "" {
+		if apiVersion == "extensions/v1beta1" {
+			annotations["kubernetes.io/ingress.class"] = codewind.IngressClass
+		} else {
+			spec["ingressClassName"] = codewind.IngressClass
+		}
+	}
+
+	if codewind.IngressTLSSecret != "" {
+		spec["tls"] = []interface{}{
+			map[string]interface{}{
+				"hosts":      []interface{}{codewind.Ingress},
+				"secretName": codewind.IngressTLSSecret,
+			},
+		}
+	}
+
+	ingress := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       "Ingress",
+			"spec":       spec,
+		},
+	}
+	ingress.SetName(constants.PFEPrefix + "-" + codewind.WorkspaceID)
+	ingress.SetNamespace(codewind.Namespace)
+	ingress.SetLabels(labels)
+	ingress.SetAnnotations(annotations)
+	ingress.SetOwnerReferences(ownerReferences(codewind))
+	return ingress
+}
+
+// ReconcileIngress creates the Codewind ingress in the given namespace, or updates the existing one if it has drifted
+func ReconcileIngress(dynamicClient dynamic.Interface, ingress *unstructured.Unstructured, namespace string, rollback *Rollback) error {
+	return reconcileUnstructured(dynamicClient.Resource(ingressResource(ingress.GetAPIVersion())).Namespace(namespace), ingress, rollback)
+}
+
+// ingressResource returns the ingresses resource of the given ingress API version
+func ingressResource(apiVersion string) schema.GroupVersionResource {
+	gv, _ := schema.ParseGroupVersion(apiVersion)
+	return gv.WithResource("ingresses")
+}
@@ -0,0 +1,173 @@
+package codewind
+
+import (
+	"fmt"
+	"testing"
+
+	"deploy-pfe/pkg/kube"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// ingressAPIResources returns the discovery information of a cluster serving ingresses in the given API versions
+func ingressAPIResources(apiVersions ...string) []*metav1.APIResourceList {
+	resources := []*metav1.APIResourceList{}
+	for _, apiVersion := range apiVersions {
+		resources = append(resources, &metav1.APIResourceList{
+			GroupVersion: apiVersion,
+			APIResources: []metav1.APIResource{{Name: "ingresses", Kind: "Ingress", Namespaced: true}},
+		})
+	}
+	return resources
+}
+
+func TestCreateIngress(t *testing.T) {
+	tests := []struct {
+		name             string
+		apiVersion       string
+		ingressClass     string
+		profile          IngressProfile
+		tlsSecret        string
+		wantServiceField []string
+		wantClassField   bool
+		wantClassAnno    bool
+	}{
+		{
+			name:             fmt.Sprintf("networking.k8s.io/v1 ingress with an ingress class and TLS"),
+			apiVersion:       "networking.k8s.io/v1",
+			ingressClass:     "nginx",
+			tlsSecret:        "codewind-tls",
+			wantServiceField: []string{"service", "name"},
+			wantClassField:   true,
+		},
+		{
+			name:             fmt.Sprintf("networking.k8s.io/v1beta1 ingress with an ingress class"),
+			apiVersion:       "networking.k8s.io/v1beta1",
+			ingressClass:     "nginx",
+			wantServiceField: []string{"serviceName"},
+			wantClassField:   true,
+		},
+		{
+			name:             fmt.Sprintf("extensions/v1beta1 ingress with an ingress class annotation"),
+			apiVersion:       "extensions/v1beta1",
+			ingressClass:     "nginx",
+			wantServiceField: []string{"serviceName"},
+			wantClassAnno:    true,
+		},
+		{
+			name:             fmt.Sprintf("GCE ingress is only selected through the ingress class annotation"),
+			apiVersion:       "networking.k8s.io/v1",
+			ingressClass:     "gce",
+			profile:          IngressProfileGCE,
+			wantServiceField: []string{"service", "name"},
+			wantClassAnno:    true,
+		},
+		{
+			name:             fmt.Sprintf("Default ingress without an ingress class or TLS"),
+			wantServiceField: []string{"service", "name"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codewindInstance := setupCodewind()
+			codewindInstance.IngressAPIVersion = tt.apiVersion
+			codewindInstance.IngressClass = tt.ingressClass
+			codewindInstance.IngressProfile = tt.profile
+			codewindInstance.IngressTLSSecret = tt.tlsSecret
+			ingress := CreateIngress(codewindInstance)
+
+			if tt.apiVersion != "" && ingress.GetAPIVersion() != tt.apiVersion {
+				t.Errorf("Ingress API version was %s, expected %s", ingress.GetAPIVersion(), tt.apiVersion)
+			}
+
+			paths, _, _ := unstructured.NestedSlice(ingress.Object, "spec", "rules")
+			rule := paths[0].(map[string]interface{})
+			httpPaths, _, _ := unstructured.NestedSlice(rule, "http", "paths")
+			backend := httpPaths[0].(map[string]interface{})["backend"].(map[string]interface{})
+			serviceName, found, _ := unstructured.NestedString(backend, tt.wantServiceField...)
+			if !found || serviceName != "codewind-"+codewindInstance.WorkspaceID {
+				t.Errorf("Ingress backend %v has no service name at %v", backend, tt.wantServiceField)
+			}
+
+			_, hasClassField, _ := unstructured.NestedString(ingress.Object, "spec", "ingressClassName")
+			_, hasClassAnno := ingress.GetAnnotations()["kubernetes.io/ingress.class"]
+			if hasClassField != tt.wantClassField || hasClassAnno != tt.wantClassAnno {
+				t.Errorf("Ingress class field set: %v, annotation set: %v, expected %v and %v", hasClassField, hasClassAnno, tt.wantClassField, tt.wantClassAnno)
+			}
+
+			tls, hasTLS, _ := unstructured.NestedSlice(ingress.Object, "spec", "tls")
+			if hasTLS != (tt.tlsSecret != "") {
+				t.Fatalf("Ingress TLS was %v, expected secret %q", tls, tt.tlsSecret)
+			}
+			if hasTLS && tls[0].(map[string]interface{})["secretName"] != tt.tlsSecret {
+				t.Errorf("Ingress TLS secret was %v, expected %s", tls[0], tt.tlsSecret)
+			}
+		})
+	}
+}
+
+func TestReconcileIngress(t *testing.T) {
+	codewindInstance := setupCodewind()
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	// Create the ingress, then update it after the TLS secret has been configured
+	err := ReconcileIngress(dynamicClient, CreateIngress(codewindInstance), codewindInstance.Namespace, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codewindInstance.IngressTLSSecret = "codewind-tls"
+	err = ReconcileIngress(dynamicClient, CreateIngress(codewindInstance), codewindInstance.Namespace, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ingress, err := dynamicClient.Resource(ingressResource("networking.k8s.io/v1")).Namespace(codewindInstance.Namespace).Get("codewind-"+codewindInstance.WorkspaceID, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, hasTLS, _ := unstructured.NestedSlice(ingress.Object, "spec", "tls"); !hasTLS {
+		t.Errorf("Ingress was not updated with the TLS secret")
+	}
+}
+
+func TestDetectIngressAPIVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		apiVersions []string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        fmt.Sprintf("Cluster serving every ingress API version"),
+			apiVersions: []string{"extensions/v1beta1", "networking.k8s.io/v1beta1", "networking.k8s.io/v1"},
+			want:        "networking.k8s.io/v1",
+		},
+		{
+			name:        fmt.Sprintf("Cluster serving the older ingress API versions"),
+			apiVersions: []string{"extensions/v1beta1", "networking.k8s.io/v1beta1"},
+			want:        "networking.k8s.io/v1beta1",
+		},
+		{
+			name:    fmt.Sprintf("Cluster without ingresses"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = ingressAPIResources(tt.apiVersions...)
+			apiVersion, err := kube.DetectIngressAPIVersion(clientset.Discovery())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if apiVersion != tt.want {
+				t.Errorf("Detected ingress API version %s, expected %s", apiVersion, tt.want)
+			}
+		})
+	}
+}
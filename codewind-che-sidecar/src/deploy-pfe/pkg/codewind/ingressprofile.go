@@ -0,0 +1,163 @@
+package codewind
+
+import (
+	"fmt"
+	"strings"
+
+	"deploy-pfe/pkg/constants"
+
+	log "github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// IngressProfile determines the annotations that the Codewind ingress (and PFE service) are given, so that the
+// ingress controller proxies HTTPS and websocket traffic to PFE correctly
+type IngressProfile string
+
+const (
+	// IngressProfileNginx targets the Kubernetes ingress-nginx controller
+	IngressProfileNginx IngressProfile = "nginx"
+
+	// IngressProfileTraefik targets Traefik v2, which reads the backend scheme from the service rather than the ingress
+	IngressProfileTraefik IngressProfile = "traefik"
+
+	// IngressProfileHAProxy targets both the HAProxy Technologies (haproxy.org) and the community
+	// (haproxy-ingress.github.io) HAProxy ingress controllers
+	IngressProfileHAProxy IngressProfile = "haproxy"
+
+	// IngressProfileGCE targets the Google Cloud load balancer ingress controller, which reads the backend protocol
+	// from the service
+	IngressProfileGCE IngressProfile = "gce"
+
+	// IngressProfileCustom doesn't add any annotations, only the ones from $INGRESS_ANNOTATIONS are used
+	IngressProfileCustom IngressProfile = "custom"
+)
+
+// pfePortName is the name of the PFE service port, which the GCE backend protocol annotation refers to
+const pfePortName = constants.PFEPrefix + "-http"
+
+// websocketTimeout is how long an idle websocket to PFE is kept open by the ingress controllers that close proxied
+// connections after a short timeout (60 seconds by default on nginx)
+const websocketTimeout = "3600"
+
+// ingressClassControllers maps (part of) the controller name of an IngressClass to the profile for that controller
+var ingressClassControllers = []struct {
+	controller string
+	profile    IngressProfile
+}{
+	{"k8s.io/ingress-nginx", IngressProfileNginx},
+	{"traefik", IngressProfileTraefik},
+	{"haproxy", IngressProfileHAProxy},
+	{"ingress-gce", IngressProfileGCE},
+}
+
+// ingressClassAPIVersions are the API versions that can serve ingress classes, from newest to oldest
+var ingressClassAPIVersions = []string{"networking.k8s.io/v1", "networking.k8s.io/v1beta1"}
+
+// ParseIngressProfile validates the name of an ingress profile, an empty name is returned as-is
+func ParseIngressProfile(name string) (IngressProfile, error) {
+	profile := IngressProfile(name)
+	switch profile {
+	case "", IngressProfileNginx, IngressProfileTraefik, IngressProfileHAProxy, IngressProfileGCE, IngressProfileCustom:
+		return profile, nil
+	}
+	return "", fmt.Errorf("invalid ingress profile %q, expected %s, %s, %s, %s or %s", name,
+		IngressProfileNginx, IngressProfileTraefik, IngressProfileHAProxy, IngressProfileGCE, IngressProfileCustom)
+}
+
+// DetectIngressProfile picks the ingress profile from the controller of the given IngressClass, or of the cluster's
+// default IngressClass if ingressClass is empty. It falls back to the default profile defined in constants/default.go
+// if the IngressClass can't be found, or its controller isn't one we have a profile for
+func DetectIngressProfile(dynamicClient dynamic.Interface, ingressClass string) IngressProfile {
+	controller, err := ingressClassController(dynamicClient, ingressClass)
+	if err != nil {
+		log.Infof("Using the %s ingress profile, as the ingress controller couldn't be detected: %v\n", constants.IngressProfile, err)
+		return IngressProfile(constants.IngressProfile)
+	}
+	for _, known := range ingressClassControllers {
+		if strings.Contains(controller, known.controller) {
+			log.Infof("Using the %s ingress profile for ingress controller %s\n", known.profile, controller)
+			return known.profile
+		}
+	}
+	log.Warnf("No ingress profile for ingress controller %s, using the %s ingress profile. Set $INGRESS_PROFILE=custom and $INGRESS_ANNOTATIONS if Codewind isn't reachable\n", controller, constants.IngressProfile)
+	return IngressProfile(constants.IngressProfile)
+}
+
+// ingressClassController returns the controller name of the given IngressClass, or of the default IngressClass if
+// ingressClass is empty
+func ingressClassController(dynamicClient dynamic.Interface, ingressClass string) (string, error) {
+	var lastErr error
+	for _, apiVersion := range ingressClassAPIVersions {
+		gv, _ := schema.ParseGroupVersion(apiVersion)
+		ingressClasses := dynamicClient.Resource(gv.WithResource("ingressclasses"))
+
+		var found *unstructured.Unstructured
+		if ingressClass != "" {
+			found, lastErr = ingressClasses.Get(ingressClass, metav1.GetOptions{})
+			if lastErr != nil {
+				continue
+			}
+		} else {
+			list, err := ingressClasses.List(metav1.ListOptions{})
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			for i := range list.Items {
+				if list.Items[i].GetAnnotations()["ingressclass.kubernetes.io/is-default-class"] == "true" {
+					found = &list.Items[i]
+					break
+				}
+			}
+			if found == nil {
+				return "", fmt.Errorf("no ingress class is set, and the cluster has no default IngressClass")
+			}
+		}
+
+		controller, _, _ := unstructured.NestedString(found.Object, "spec", "controller")
+		if controller == "" {
+			return "", fmt.Errorf("IngressClass %s has no controller", found.GetName())
+		}
+		return controller, nil
+	}
+	return "", lastErr
+}
+
+// ingressProfileAnnotations returns the ingress and PFE service annotations of the given ingress profile
+func ingressProfileAnnotations(profile IngressProfile) (map[string]string, map[string]string) {
+	switch profile {
+	case IngressProfileTraefik:
+		// Traefik proxies websockets without any extra settings
+		return map[string]string{}, map[string]string{
+			"traefik.ingress.kubernetes.io/service.serversscheme": "https",
+		}
+	case IngressProfileHAProxy:
+		return map[string]string{
+			"haproxy.org/server-ssl":                     "true",
+			"haproxy.org/timeout-tunnel":                 websocketTimeout + "s",
+			"haproxy-ingress.github.io/backend-protocol": "h1-ssl",
+			"haproxy-ingress.github.io/timeout-tunnel":   websocketTimeout + "s",
+		}, map[string]string{}
+	case IngressProfileGCE:
+		// The GCE ingress controller predates ingress classes, so is only selected through the annotation
+		return map[string]string{
+			"kubernetes.io/ingress.class": "gce",
+		}, map[string]string{
+			"cloud.google.com/app-protocols": `{"` + pfePortName + `":"HTTPS"}`,
+			"cloud.google.com/neg":           `{"ingress":true}`,
+		}
+	case IngressProfileCustom:
+		return map[string]string{}, map[string]string{}
+	}
+	return map[string]string{
+		"nginx.ingress.kubernetes.io/rewrite-target":     "/",
+		"nginx.ingress.kubernetes.io/backend-protocol":   "HTTPS",
+		"nginx.ingress.kubernetes.io/proxy-read-timeout": websocketTimeout,
+		"nginx.ingress.kubernetes.io/proxy-send-timeout": websocketTimeout,
+	}, map[string]string{}
+}
@@ -0,0 +1,192 @@
+package codewind
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// setupIngressClass returns an IngressClass for the given controller, optionally marked as the cluster default
+func setupIngressClass(name string, controller string, isDefault bool) *unstructured.Unstructured {
+	ingressClass := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "networking.k8s.io/v1",
+			"kind":       "IngressClass",
+			"metadata": map[string]interface{}{
+				"name": name,
+			},
+			"spec": map[string]interface{}{
+				"controller": controller,
+			},
+		},
+	}
+	if isDefault {
+		ingressClass.SetAnnotations(map[string]string{"ingressclass.kubernetes.io/is-default-class": "true"})
+	}
+	return ingressClass
+}
+
+func TestIngressProfileAnnotations(t *testing.T) {
+	tests := []struct {
+		name               string
+		profile            IngressProfile
+		extra              map[string]string
+		ingressAnnotations map[string]string
+		serviceAnnotations map[string]string
+	}{
+		{
+			name:    fmt.Sprintf("nginx profile"),
+			profile: IngressProfileNginx,
+			ingressAnnotations: map[string]string{
+				"nginx.ingress.kubernetes.io/backend-protocol":   "HTTPS",
+				"nginx.ingress.kubernetes.io/proxy-read-timeout": "3600",
+			},
+		},
+		{
+			name:    fmt.Sprintf("Default profile is nginx"),
+			profile: "",
+			ingressAnnotations: map[string]string{
+				"nginx.ingress.kubernetes.io/backend-protocol": "HTTPS",
+			},
+		},
+		{
+			name:    fmt.Sprintf("traefik profile"),
+			profile: IngressProfileTraefik,
+			serviceAnnotations: map[string]string{
+				"traefik.ingress.kubernetes.io/service.serversscheme": "https",
+			},
+		},
+		{
+			name:    fmt.Sprintf("haproxy profile"),
+			profile: IngressProfileHAProxy,
+			ingressAnnotations: map[string]string{
+				"haproxy.org/server-ssl":                     "true",
+				"haproxy-ingress.github.io/backend-protocol": "h1-ssl",
+			},
+		},
+		{
+			name:    fmt.Sprintf("gce profile"),
+			profile: IngressProfileGCE,
+			ingressAnnotations: map[string]string{
+				"kubernetes.io/ingress.class": "gce",
+			},
+			serviceAnnotations: map[string]string{
+				"cloud.google.com/app-protocols": `{"codewind-http":"HTTPS"}`,
+			},
+		},
+		{
+			name:    fmt.Sprintf("custom profile with extra annotations"),
+			profile: IngressProfileCustom,
+			extra:   map[string]string{"contour.heptio.com/upstream-protocol.tls": "9191"},
+			ingressAnnotations: map[string]string{
+				"contour.heptio.com/upstream-protocol.tls": "9191",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codewindInstance := setupCodewind()
+			codewindInstance.IngressProfile = tt.profile
+			codewindInstance.IngressAnnotations = tt.extra
+			ingress := CreateIngress(codewindInstance)
+			service := createPFEService(codewindInstance)
+
+			for key, value := range tt.ingressAnnotations {
+				if ingress.GetAnnotations()[key] != value {
+					t.Errorf("Ingress annotation %s was %q, expected %q", key, ingress.GetAnnotations()[key], value)
+				}
+			}
+			for key, value := range tt.serviceAnnotations {
+				if service.Annotations[key] != value {
+					t.Errorf("Service annotation %s was %q, expected %q", key, service.Annotations[key], value)
+				}
+			}
+			if tt.profile == IngressProfileCustom && len(ingress.GetAnnotations()) != len(tt.extra) {
+				t.Errorf("Custom profile ingress had annotations %v, expected only %v", ingress.GetAnnotations(), tt.extra)
+			}
+		})
+	}
+}
+
+func TestDetectIngressProfile(t *testing.T) {
+	tests := []struct {
+		name           string
+		ingressClass   string
+		ingressClasses []runtime.Object
+		want           IngressProfile
+	}{
+		{
+			name:           fmt.Sprintf("Profile from the configured ingress class"),
+			ingressClass:   "traefik",
+			ingressClasses: []runtime.Object{setupIngressClass("traefik", "traefik.io/ingress-controller", false), setupIngressClass("nginx", "k8s.io/ingress-nginx", true)},
+			want:           IngressProfileTraefik,
+		},
+		{
+			name:           fmt.Sprintf("Profile from the default ingress class"),
+			ingressClasses: []runtime.Object{setupIngressClass("traefik", "traefik.io/ingress-controller", false), setupIngressClass("haproxy", "haproxy.org/ingress-controller/haproxy", true)},
+			want:           IngressProfileHAProxy,
+		},
+		{
+			name:           fmt.Sprintf("Unknown controller falls back to nginx"),
+			ingressClass:   "contour",
+			ingressClasses: []runtime.Object{setupIngressClass("contour", "projectcontour.io/contour", false)},
+			want:           IngressProfileNginx,
+		},
+		{
+			name:         fmt.Sprintf("Missing ingress class falls back to nginx"),
+			ingressClass: "missing",
+			want:         IngressProfileNginx,
+		},
+		{
+			name: fmt.Sprintf("No default ingress class falls back to nginx"),
+			want: IngressProfileNginx,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), tt.ingressClasses...)
+			profile := DetectIngressProfile(dynamicClient, tt.ingressClass)
+			if profile != tt.want {
+				t.Errorf("Detected ingress profile %s, expected %s", profile, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIngressProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		want    IngressProfile
+		wantErr bool
+	}{
+		{
+			name:    fmt.Sprintf("Profile set"),
+			profile: "haproxy",
+			want:    IngressProfileHAProxy,
+		},
+		{
+			name: fmt.Sprintf("Nothing set, profile is detected"),
+			want: "",
+		},
+		{
+			name:    fmt.Sprintf("Invalid profile"),
+			profile: "apache",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := ParseIngressProfile(tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if profile != tt.want {
+				t.Errorf("Ingress profile was %s, expected %s", profile, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package codewind
+
+import (
+	"fmt"
+
+	"deploy-pfe/pkg/che"
+	"deploy-pfe/pkg/config"
+	"deploy-pfe/pkg/constants"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// OwnershipStrategy determines which object owns the Codewind resources, and thus when they're garbage collected
+type OwnershipStrategy string
+
+const (
+	// OwnershipDeployment ties Codewind to the owner of the workspace pod (usually the workspace Deployment), so it's
+	// removed when the workspace is stopped
+	OwnershipDeployment OwnershipStrategy = "deployment"
+
+	// OwnershipPVC ties Codewind to the workspace PVC, so it survives the workspace being stopped and started, and
+	// is removed along with the workspace's volume
+	OwnershipPVC OwnershipStrategy = "pvc"
+
+	// OwnershipConfigMap ties Codewind to a dedicated "codewind-<workspace>" anchor ConfigMap that isn't owned by
+	// anything, so it's only removed when the anchor is deleted (such as by `deploy-pfe teardown`)
+	OwnershipConfigMap OwnershipStrategy = "configmap"
+)
+
+// GetOwnershipStrategy returns the ownership strategy to use for the Codewind resources: deployment, pvc or configmap.
+// It defaults to the strategy defined in constants/default.go if it's empty
+func GetOwnershipStrategy(settings *config.Config) (OwnershipStrategy, error) {
+	strategy := OwnershipStrategy(settings.Ownership)
+	if strategy == "" {
+		strategy = constants.OwnershipStrategy
+	}
+	switch strategy {
+	case OwnershipDeployment, OwnershipPVC, OwnershipConfigMap:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("invalid ownership strategy %q, expected %s, %s or %s", strategy, OwnershipDeployment, OwnershipPVC, OwnershipConfigMap)
+}
+
+// GetOwner returns the object that the Codewind resources of the workspace should be owned by, for the given strategy.
+// For the configmap strategy, the anchor ConfigMap is created if it doesn't exist yet, and recorded in the rollback
+func GetOwner(clientset kubernetes.Interface, namespace string, workspaceID string, strategy OwnershipStrategy, rollback *Rollback) (metav1.OwnerReference, error) {
+	switch strategy {
+	case OwnershipPVC:
+		pvc, err := che.GetWorkspacePVC(clientset, namespace, workspaceID)
+		if err != nil {
+			return metav1.OwnerReference{}, err
+		}
+		return metav1.OwnerReference{
+			APIVersion: "v1",
+			Kind:       "PersistentVolumeClaim",
+			Name:       pvc.GetName(),
+			UID:        pvc.GetUID(),
+		}, nil
+	case OwnershipConfigMap:
+		anchor, err := getAnchor(clientset, namespace, workspaceID, rollback)
+		if err != nil {
+			return metav1.OwnerReference{}, err
+		}
+		return metav1.OwnerReference{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Name:       anchor.GetName(),
+			UID:        anchor.GetUID(),
+		}, nil
+	}
+	return che.GetOwnerReferences(clientset, namespace, workspaceID)
+}
+
+// getAnchor retrieves the anchor ConfigMap of the workspace, creating it if it doesn't exist yet
+func getAnchor(clientset kubernetes.Interface, namespace string, workspaceID string, rollback *Rollback) (*corev1.ConfigMap, error) {
+	configMaps := clientset.CoreV1().ConfigMaps(namespace)
+	name := constants.PFEPrefix + "-" + workspaceID
+	anchor, err := configMaps.Get(name, metav1.GetOptions{})
+	if err == nil {
+		return anchor, nil
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	anchor, err = configMaps.Create(&corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app":               constants.PFEPrefix,
+				"codewindWorkspace": workspaceID,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Created anchor ConfigMap %s\n", name)
+	rollback.record("anchor configmap", name, func() error {
+		return configMaps.Delete(name, rollbackDeleteOptions())
+	})
+	return anchor, nil
+}
@@ -0,0 +1,108 @@
+package codewind
+
+import (
+	"fmt"
+	"testing"
+
+	"deploy-pfe/pkg/config"
+	"deploy-pfe/pkg/constants"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetOwner(t *testing.T) {
+	codewindInstance := setupCodewind()
+	tests := []struct {
+		name     string
+		strategy OwnershipStrategy
+		kind     string
+		owner    string
+	}{
+		{
+			name:     fmt.Sprintf("Codewind is owned by the workspace PVC"),
+			strategy: OwnershipPVC,
+			kind:     "PersistentVolumeClaim",
+			owner:    "claim-che-workspace",
+		},
+		{
+			name:     fmt.Sprintf("Codewind is owned by an anchor ConfigMap"),
+			strategy: OwnershipConfigMap,
+			kind:     "ConfigMap",
+			owner:    constants.PFEPrefix + "-" + codewindInstance.WorkspaceID,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(setupWorkspacePVC(codewindInstance), setupStorageClass())
+			owner, err := GetOwner(clientset, codewindInstance.Namespace, codewindInstance.WorkspaceID, tt.strategy, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if owner.Kind != tt.kind || owner.Name != tt.owner || owner.APIVersion != "v1" {
+				t.Errorf("Codewind is owned by %v %v %v, expected v1 %v %v", owner.APIVersion, owner.Kind, owner.Name, tt.kind, tt.owner)
+			}
+
+			// Getting the owner again, such as after a workspace restart, should return the same owner
+			again, err := GetOwner(clientset, codewindInstance.Namespace, codewindInstance.WorkspaceID, tt.strategy, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.UID != owner.UID {
+				t.Errorf("Owner changed from %v to %v between two deploys", owner.UID, again.UID)
+			}
+			if tt.strategy == OwnershipConfigMap {
+				if _, err := clientset.CoreV1().ConfigMaps(codewindInstance.Namespace).Get(tt.owner, metav1.GetOptions{}); err != nil {
+					t.Errorf("Anchor ConfigMap was not created: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestGetOwnershipStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		setting  string
+		strategy OwnershipStrategy
+		valid    bool
+	}{
+		{
+			name:     fmt.Sprintf("Default an empty strategy to the workspace deployment"),
+			setting:  "",
+			strategy: OwnershipDeployment,
+			valid:    true,
+		},
+		{
+			name:     fmt.Sprintf("Use the anchor ConfigMap"),
+			setting:  "configmap",
+			strategy: OwnershipConfigMap,
+			valid:    true,
+		},
+		{
+			name:    fmt.Sprintf("Reject an unknown strategy"),
+			setting: "replicaset",
+			valid:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.Default()
+			settings.Ownership = tt.setting
+
+			strategy, err := GetOwnershipStrategy(settings)
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("Ownership strategy %v wasn't rejected", tt.setting)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strategy != tt.strategy {
+				t.Errorf("Ownership strategy is %v, expected %v", strategy, tt.strategy)
+			}
+		})
+	}
+}
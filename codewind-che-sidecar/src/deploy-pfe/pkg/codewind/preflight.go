@@ -0,0 +1,257 @@
+package codewind
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/tabwriter"
+
+	"deploy-pfe/pkg/constants"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Names of the cluster roles in setup/install_che/codewind-clusterrole.yaml, which grant the permissions that deploy-pfe
+// needs to the Che workspace service accounts. The namespaced permissions are bound with a role binding, while the
+// cluster scoped ones (such as reading storage classes) are split into a cluster role of their own, bound with a
+// cluster role binding
+const (
+	ClusterRoleName       = "eclipse-codewind"
+	ClusterScopedRoleName = "eclipse-codewind-cluster"
+)
+
+// Permission is a verb on a resource that deploy-pfe needs, and what it needs it for
+type Permission struct {
+	Verb      string
+	Group     string
+	Resource  string
+	Namespace string
+	Name      string
+	NeededFor string
+}
+
+// String returns the permission as verb resource.group, such as create deployments.apps
+func (p Permission) String() string {
+	if p.Group == "" {
+		return p.Verb + " " + p.Resource
+	}
+	return p.Verb + " " + p.Resource + "." + p.Group
+}
+
+// RequiredPermissions returns every permission that deploying the given Codewind instance needs, depending on its
+// settings: the volume, ownership and exposure strategies, and whether the image pull secret is added to the
+// workspace service account. This includes deleting whatever a failed deployment created, which is rolled back
+func RequiredPermissions(codewind Codewind, ownership OwnershipStrategy) []Permission {
+	namespace := codewind.Namespace
+	namespaced := func(neededFor string, group string, resource string, verbs ...string) []Permission {
+		permissions := []Permission{}
+		for _, verb := range verbs {
+			permissions = append(permissions, Permission{Verb: verb, Group: group, Resource: resource, Namespace: namespace, NeededFor: neededFor})
+		}
+		return permissions
+	}
+	clusterScoped := func(neededFor string, group string, resource string, verbs ...string) []Permission {
+		permissions := namespaced(neededFor, group, resource, verbs...)
+		for i := range permissions {
+			permissions[i].Namespace = ""
+		}
+		return permissions
+	}
+
+	permissions := []Permission{}
+	permissions = append(permissions, namespaced("finding the Che workspace pod", "", "pods", "list")...)
+	permissions = append(permissions, namespaced("finding the Che workspace volume", "", "persistentvolumeclaims", "get", "list")...)
+	switch ownership {
+	case OwnershipConfigMap:
+		permissions = append(permissions, namespaced("creating the anchor ConfigMap", "", "configmaps", "get", "create")...)
+		permissions = append(permissions, namespaced("rolling back the anchor ConfigMap", "", "configmaps", "delete")...)
+	case OwnershipPVC:
+	default:
+		permissions = append(permissions, namespaced("finding the owner of the Che workspace pod", "apps", "replicasets", "get")...)
+	}
+	permissions = append(permissions, Permission{Verb: "get", Resource: "namespaces", Namespace: namespace, Name: namespace, NeededFor: "checking the Pod Security Standards of the namespace"})
+
+	if codewind.WorkspaceVolume == nil && !codewind.ShareWorkspaceVolume {
+		permissions = append(permissions, clusterScoped("choosing a ReadWriteMany storage class", "storage.k8s.io", "storageclasses", "get", "list")...)
+		permissions = append(permissions, namespaced("creating and expanding the Codewind volume", "", "persistentvolumeclaims", "create", "update")...)
+		permissions = append(permissions, namespaced("rolling back the Codewind volume", "", "persistentvolumeclaims", "delete")...)
+		if codewind.PVCBindTimeout > 0 {
+			permissions = append(permissions, namespaced("telling why the Codewind volume isn't bound, or that it binds on first use", "", "events", "list")...)
+		}
+	}
+
+	permissions = append(permissions, namespaced("finding the image pull secret", "", "secrets", "get")...)
+	if codewind.PatchServiceAccount {
+		permissions = append(permissions, namespaced("adding the image pull secret to the service account", "", "serviceaccounts", "get", "patch")...)
+	}
+
+	permissions = append(permissions, namespaced("deploying the Codewind services", "", "services", "get", "create", "update")...)
+	permissions = append(permissions, namespaced("rolling back the Codewind services", "", "services", "delete")...)
+	permissions = append(permissions, namespaced("deploying the Codewind deployments", "apps", "deployments", "get", "create", "update")...)
+	permissions = append(permissions, namespaced("rolling back the Codewind deployments", "apps", "deployments", "delete")...)
+
+	switch exposureOf(codewind) {
+	case ExposureRoute:
+		permissions = append(permissions, namespaced("exposing Codewind through a route", "route.openshift.io", "routes", "get", "create", "update")...)
+		permissions = append(permissions, namespaced("rolling back the Codewind route", "route.openshift.io", "routes", "delete")...)
+	case ExposureIngress:
+		permissions = append(permissions, namespaced("exposing Codewind through an ingress", ingressGroupOf(codewind), "ingresses", "get", "create", "update")...)
+		permissions = append(permissions, namespaced("rolling back the Codewind ingress", ingressGroupOf(codewind), "ingresses", "delete")...)
+		if codewind.IngressProfile == "" {
+			permissions = append(permissions, clusterScoped("detecting the ingress controller", "networking.k8s.io", "ingressclasses", "get", "list")...)
+		}
+	case ExposureGateway:
+		kind := codewind.Gateway.RouteKind
+		if kind == "" {
+			kind = GatewayTLSRoute
+		}
+		permissions = append(permissions, namespaced("exposing Codewind through a Gateway", "gateway.networking.k8s.io", gatewayRouteResources[kind], "get", "create", "update")...)
+		permissions = append(permissions, namespaced("rolling back the Codewind Gateway API route", "gateway.networking.k8s.io", gatewayRouteResources[kind], "delete")...)
+	default:
+		if codewind.OnOpenShift {
+			permissions = append(permissions, namespaced("removing the route of a previous deployment", "route.openshift.io", "routes", "list", "delete")...)
+		}
+		permissions = append(permissions, namespaced("removing the ingress of a previous deployment", ingressGroupOf(codewind), "ingresses", "list", "delete")...)
+	}
+	return permissions
+}
+
+// ingressGroupOf returns the API group that ingresses are managed in, from the ingress API version detected on the
+// cluster (such as extensions on older clusters)
+func ingressGroupOf(codewind Codewind) string {
+	apiVersion := codewind.IngressAPIVersion
+	if apiVersion == "" {
+		apiVersion = constants.IngressAPIVersion
+	}
+	gv, _ := schema.ParseGroupVersion(apiVersion)
+	return gv.Group
+}
+
+// CheckPermissions asks the API server whether the current user (the workspace service account) has each of the given
+// permissions, through SelfSubjectAccessReviews, and returns the ones it doesn't have
+func CheckPermissions(clientset kubernetes.Interface, permissions []Permission) ([]Permission, error) {
+	missing := []Permission{}
+	for _, permission := range permissions {
+		review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: permission.Namespace,
+					Verb:      permission.Verb,
+					Group:     permission.Group,
+					Resource:  permission.Resource,
+					Name:      permission.Name,
+				},
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to check permission to %s: %v", permission, err)
+		}
+		if !review.Status.Allowed {
+			missing = append(missing, permission)
+		}
+	}
+	return missing, nil
+}
+
+// LoadClusterRoles parses the cluster roles from a manifest, such as setup/install_che/codewind-clusterrole.yaml, which
+// may hold other documents (such as their bindings) as well
+func LoadClusterRoles(path string) ([]*rbacv1.ClusterRole, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	clusterRoles := []*rbacv1.ClusterRole{}
+	for _, document := range strings.Split(string(data), "\n---") {
+		clusterRole := &rbacv1.ClusterRole{}
+		err = yaml.Unmarshal([]byte(document), clusterRole)
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest %s: %v", path, err)
+		}
+		if clusterRole.Kind == "ClusterRole" {
+			clusterRoles = append(clusterRoles, clusterRole)
+		}
+	}
+	if len(clusterRoles) == 0 {
+		return nil, fmt.Errorf("no cluster role found in manifest %s", path)
+	}
+	return clusterRoles, nil
+}
+
+// GetClusterRoles retrieves the Codewind cluster roles from the cluster, for when no cluster role manifest was given
+func GetClusterRoles(clientset kubernetes.Interface) ([]*rbacv1.ClusterRole, error) {
+	clusterRoles := []*rbacv1.ClusterRole{}
+	for _, name := range []string{ClusterRoleName, ClusterScopedRoleName} {
+		clusterRole, err := clientset.RbacV1().ClusterRoles().Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		clusterRoles = append(clusterRoles, clusterRole)
+	}
+	return clusterRoles, nil
+}
+
+// ClusterRoleGrants returns true if a rule of the cluster role grants the permission
+func ClusterRoleGrants(clusterRole *rbacv1.ClusterRole, permission Permission) bool {
+	for _, rule := range clusterRole.Rules {
+		if ruleMatches(rule.APIGroups, permission.Group) && ruleMatches(rule.Resources, permission.Resource) && ruleMatches(rule.Verbs, permission.Verb) &&
+			(len(rule.ResourceNames) == 0 || ruleMatches(rule.ResourceNames, permission.Name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleMatches returns true if the values of a policy rule hold the value, or the * wildcard
+func ruleMatches(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || v == rbacv1.VerbAll {
+			return true
+		}
+	}
+	return false
+}
+
+// WritePermissionTable writes a table of the missing permissions, and which of the cluster roles grants each of them.
+// A permission that a cluster role grants means that the cluster role isn't bound to the service account (cluster
+// scoped permissions need a cluster role binding, as a role binding only grants namespaced ones), while one that none
+// of them grants means that the cluster roles are out of date. The cluster role column is left out if there are none
+func WritePermissionTable(out io.Writer, missing []Permission, clusterRoles []*rbacv1.ClusterRole) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	header := []string{"VERB", "RESOURCE", "SCOPE", "NEEDED FOR"}
+	if len(clusterRoles) > 0 {
+		header = append(header, "CLUSTER ROLES")
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, permission := range missing {
+		resource := permission.Resource
+		if permission.Group != "" {
+			resource += "." + permission.Group
+		}
+		scope := "namespace " + permission.Namespace
+		if permission.Namespace == "" {
+			scope = "cluster"
+		}
+		row := []string{permission.Verb, resource, scope, permission.NeededFor}
+		if len(clusterRoles) > 0 {
+			advice := "not granted, update the cluster roles"
+			for _, clusterRole := range clusterRoles {
+				if ClusterRoleGrants(clusterRole, permission) {
+					advice = "granted by " + clusterRole.Name + ", bind it to the service account"
+					if permission.Namespace == "" {
+						advice += " with a cluster role binding"
+					}
+					break
+				}
+			}
+			row = append(row, advice)
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,278 @@
+package codewind
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
+)
+
+// clusterRolePath is the Codewind cluster role manifest, relative to this package
+const clusterRolePath = "../../../../../setup/install_che/codewind-clusterrole.yaml"
+
+func TestRequiredPermissionsGrantedByClusterRole(t *testing.T) {
+	if _, err := os.Stat(clusterRolePath); os.IsNotExist(err) {
+		t.Skipf("Cluster role manifest %s not found", clusterRolePath)
+	}
+	clusterRoles, err := LoadClusterRoles(clusterRolePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles := map[string]*rbacv1.ClusterRole{}
+	for _, clusterRole := range clusterRoles {
+		roles[clusterRole.Name] = clusterRole
+	}
+	if roles[ClusterRoleName] == nil || roles[ClusterScopedRoleName] == nil {
+		t.Fatalf("Cluster role manifest %s doesn't hold cluster roles %s and %s", clusterRolePath, ClusterRoleName, ClusterScopedRoleName)
+	}
+
+	// The cluster scoped permissions are only granted by a cluster role binding, which the manifest has to hold
+	data, err := ioutil.ReadFile(clusterRolePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bound := false
+	for _, document := range strings.Split(string(data), "\n---") {
+		binding := &rbacv1.ClusterRoleBinding{}
+		if err := yaml.Unmarshal([]byte(document), binding); err == nil && binding.Kind == "ClusterRoleBinding" && binding.RoleRef.Name == ClusterScopedRoleName {
+			bound = true
+		}
+	}
+	if !bound {
+		t.Errorf("Cluster role manifest %s doesn't bind cluster role %s with a cluster role binding", clusterRolePath, ClusterScopedRoleName)
+	}
+
+	route := setupCodewind()
+	route.OnOpenShift = true
+	route.PVCBindTimeout = 1
+	ingress := setupCodewind()
+	ingress.Exposure = ExposureIngress
+	ingress.PatchServiceAccount = true
+	extensionsIngress := setupCodewind()
+	extensionsIngress.Exposure = ExposureIngress
+	extensionsIngress.IngressAPIVersion = "extensions/v1beta1"
+	gateway := setupCodewind()
+	gateway.Exposure = ExposureGateway
+	gateway.Gateway.RouteKind = GatewayHTTPRoute
+	none := setupCodewind()
+	none.Exposure = ExposureNone
+	none.OnOpenShift = true
+	none.ShareWorkspaceVolume = true
+
+	tests := []struct {
+		name      string
+		codewind  Codewind
+		ownership OwnershipStrategy
+	}{
+		{
+			name:      fmt.Sprintf("Route exposure owned by the workspace deployment"),
+			codewind:  route,
+			ownership: OwnershipDeployment,
+		},
+		{
+			name:      fmt.Sprintf("Ingress exposure owned by an anchor ConfigMap"),
+			codewind:  ingress,
+			ownership: OwnershipConfigMap,
+		},
+		{
+			name:      fmt.Sprintf("Ingress exposure through the extensions API group"),
+			codewind:  extensionsIngress,
+			ownership: OwnershipDeployment,
+		},
+		{
+			name:      fmt.Sprintf("Gateway exposure owned by the workspace PVC"),
+			codewind:  gateway,
+			ownership: OwnershipPVC,
+		},
+		{
+			name:      fmt.Sprintf("No exposure on OpenShift"),
+			codewind:  none,
+			ownership: OwnershipDeployment,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, permission := range RequiredPermissions(tt.codewind, tt.ownership) {
+				// Cluster scoped permissions have to be granted by the cluster role with the cluster role binding
+				clusterRole := roles[ClusterRoleName]
+				if permission.Namespace == "" {
+					clusterRole = roles[ClusterScopedRoleName]
+				}
+				if !ClusterRoleGrants(clusterRole, permission) {
+					t.Errorf("Cluster role %s doesn't grant %s, needed for %s", clusterRole.Name, permission, permission.NeededFor)
+				}
+			}
+		})
+	}
+}
+
+// TestRequiredPermissionsCoverRollback verifies that every object that rolling back a failed deployment deletes is
+// covered by a delete permission
+func TestRequiredPermissionsCoverRollback(t *testing.T) {
+	codewindInstance := setupCodewind()
+	codewindInstance.Exposure = ExposureIngress
+	clientset := fake.NewSimpleClientset(setupWorkspacePVC(codewindInstance), setupStorageClass())
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	rollback := NewRollback()
+	_, err := GetOwner(clientset, codewindInstance.Namespace, codewindInstance.WorkspaceID, OwnershipConfigMap, rollback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = DeployCodewind(clientset, codewindInstance, codewindInstance.Namespace, rollback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ReconcileIngress(dynamicClient, CreateIngress(codewindInstance), codewindInstance.Namespace, rollback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed := rollback.Run(); failed != 0 {
+		t.Fatalf("Rollback failed to delete %d objects", failed)
+	}
+
+	permissions := RequiredPermissions(codewindInstance, OwnershipConfigMap)
+	actions := append(clientset.Actions(), dynamicClient.Actions()...)
+	deleted := 0
+	for _, action := range actions {
+		if action.GetVerb() != "delete" {
+			continue
+		}
+		deleted++
+		resource := action.GetResource()
+		covered := false
+		for _, permission := range permissions {
+			if permission.Verb == "delete" && permission.Group == resource.Group && permission.Resource == resource.Resource {
+				covered = true
+			}
+		}
+		if !covered {
+			t.Errorf("Rollback deletes %s.%s, which isn't covered by a required permission", resource.Resource, resource.Group)
+		}
+	}
+	if deleted == 0 {
+		t.Errorf("Rollback didn't delete anything")
+	}
+}
+
+func TestCheckPermissions(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = !(attributes.Resource == "deployments" && attributes.Verb == "update") && attributes.Resource != "storageclasses"
+		return true, review, nil
+	})
+
+	missing, err := CheckPermissions(clientset, RequiredPermissions(setupCodewind(), OwnershipDeployment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, permission := range missing {
+		got = append(got, permission.String())
+	}
+	want := []string{"get storageclasses.storage.k8s.io", "list storageclasses.storage.k8s.io", "update deployments.apps"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("CheckPermissions returned %v, expected %v", got, want)
+	}
+}
+
+func TestRequiredIngressPermissions(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiVersion string
+		group      string
+	}{
+		{
+			name:  fmt.Sprintf("Default ingress API version"),
+			group: "networking.k8s.io",
+		},
+		{
+			name:       fmt.Sprintf("Ingresses served by networking.k8s.io/v1beta1"),
+			apiVersion: "networking.k8s.io/v1beta1",
+			group:      "networking.k8s.io",
+		},
+		{
+			name:       fmt.Sprintf("Ingresses only served by extensions/v1beta1"),
+			apiVersion: "extensions/v1beta1",
+			group:      "extensions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codewindInstance := setupCodewind()
+			codewindInstance.Exposure = ExposureIngress
+			codewindInstance.IngressAPIVersion = tt.apiVersion
+			found := false
+			for _, permission := range RequiredPermissions(codewindInstance, OwnershipDeployment) {
+				if permission.Resource == "ingresses" {
+					found = true
+					if permission.Group != tt.group {
+						t.Errorf("Permission to %s is checked in group %q, expected %q", permission, permission.Group, tt.group)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("No permissions on ingresses are required")
+			}
+		})
+	}
+}
+
+func TestWritePermissionTable(t *testing.T) {
+	clusterRole := &rbacv1.ClusterRole{
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{"apps"},
+				Resources: []string{"deployments"},
+				Verbs:     []string{"*"},
+			},
+		},
+	}
+	clusterRole.Name = ClusterRoleName
+	clusterScopedRole := &rbacv1.ClusterRole{
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{"storage.k8s.io"},
+				Resources: []string{"storageclasses"},
+				Verbs:     []string{"get"},
+			},
+		},
+	}
+	clusterScopedRole.Name = ClusterScopedRoleName
+	missing := []Permission{
+		{Verb: "create", Group: "apps", Resource: "deployments", Namespace: "default", NeededFor: "deploying the Codewind deployments"},
+		{Verb: "list", Group: "storage.k8s.io", Resource: "storageclasses", NeededFor: "choosing a ReadWriteMany storage class"},
+		{Verb: "get", Group: "storage.k8s.io", Resource: "storageclasses", NeededFor: "choosing a ReadWriteMany storage class"},
+	}
+
+	var b bytes.Buffer
+	err := WritePermissionTable(&b, missing, []*rbacv1.ClusterRole{clusterRole, clusterScopedRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Permission table has %d lines, expected %d:\n%s", len(lines), 4, b.String())
+	}
+	if !strings.Contains(lines[1], "namespace default") || !strings.Contains(lines[1], "granted by "+ClusterRoleName+", bind it") {
+		t.Errorf("Permission table row %q doesn't show a namespaced permission granted by the cluster role", lines[1])
+	}
+	if !strings.Contains(lines[2], "cluster") || !strings.Contains(lines[2], "not granted") {
+		t.Errorf("Permission table row %q doesn't show a cluster permission missing from the cluster roles", lines[2])
+	}
+	if !strings.Contains(lines[3], "granted by "+ClusterScopedRoleName) || !strings.Contains(lines[3], "cluster role binding") {
+		t.Errorf("Permission table row %q doesn't show that a cluster permission needs a cluster role binding", lines[3])
+	}
+}
@@ -0,0 +1,87 @@
+package codewind
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// workspaceRegistrySecretSuffix is the suffix of the secret that Che creates for the registries of a workspace
+const workspaceRegistrySecretSuffix = "-registry-secrets"
+
+// ResolvePullSecret returns the image pull secret for the Codewind images: the configured secret, which must exist, or
+// the `<workspace>-registry-secrets` secret of the Che workspace if there is one. An empty name is returned if there
+// is no pull secret, such as when the images are pulled from a public registry
+func ResolvePullSecret(clientset kubernetes.Interface, namespace string, workspaceID string, pullSecret string) (string, error) {
+	if pullSecret != "" {
+		_, err := clientset.CoreV1().Secrets(namespace).Get(pullSecret, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return "", fmt.Errorf("image pull secret %s doesn't exist", pullSecret)
+		} else if err != nil {
+			return "", fmt.Errorf("unable to retrieve image pull secret %s: %v", pullSecret, err)
+		}
+		log.Infof("Using image pull secret %s, as it was set explicitly\n", pullSecret)
+		return pullSecret, nil
+	}
+
+	workspaceSecret := workspaceID + workspaceRegistrySecretSuffix
+	_, err := clientset.CoreV1().Secrets(namespace).Get(workspaceSecret, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		log.Infof("No image pull secret set, and Che workspace registry secret %s doesn't exist\n", workspaceSecret)
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("unable to retrieve Che workspace registry secret %s: %v", workspaceSecret, err)
+	}
+	log.Infof("Using Che workspace registry secret %s as image pull secret\n", workspaceSecret)
+	return workspaceSecret, nil
+}
+
+// imagePullSecrets returns the image pull secrets of the Codewind pods
+func imagePullSecrets(codewind Codewind) []corev1.LocalObjectReference {
+	if codewind.PullSecret == "" {
+		return nil
+	}
+	return []corev1.LocalObjectReference{{Name: codewind.PullSecret}}
+}
+
+// PatchServiceAccount adds the image pull secret of Codewind to the specified service account. The secrets that the
+// service account already has are kept, as its imagePullSecrets list is replaced as a whole when patched
+func PatchServiceAccount(clientset kubernetes.Interface, codewind Codewind) error {
+	serviceAccount, err := clientset.CoreV1().ServiceAccounts(codewind.Namespace).Get(codewind.ServiceAccountName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	secrets := []ImagePullSecret{}
+	for _, secret := range serviceAccount.ImagePullSecrets {
+		if secret.Name == codewind.PullSecret {
+			log.Infof("Service account %s already has image pull secret %s\n", codewind.ServiceAccountName, codewind.PullSecret)
+			return nil
+		}
+		secrets = append(secrets, ImagePullSecret{Name: secret.Name})
+	}
+	secrets = append(secrets, ImagePullSecret{Name: codewind.PullSecret})
+
+	patch := ServiceAccountPatch{
+		ImagePullSecrets: &secrets,
+	}
+
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	_, err = clientset.CoreV1().ServiceAccounts(codewind.Namespace).Patch(codewind.ServiceAccountName, types.StrategicMergePatchType, b)
+	if err != nil {
+		return err
+	}
+	log.Infof("Added image pull secret %s to service account %s\n", codewind.PullSecret, codewind.ServiceAccountName)
+	return nil
+}
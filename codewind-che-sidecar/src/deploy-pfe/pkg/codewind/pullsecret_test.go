@@ -0,0 +1,118 @@
+package codewind
+
+import (
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newSecret returns a secret with the given name in the default namespace
+func newSecret(name string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+	}
+}
+
+func TestResolvePullSecret(t *testing.T) {
+	codewindInstance := setupCodewind()
+	workspaceSecret := codewindInstance.WorkspaceID + "-registry-secrets"
+
+	tests := []struct {
+		name       string
+		pullSecret string
+		objects    []runtime.Object
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       fmt.Sprintf("Configured pull secret"),
+			pullSecret: "private-registry",
+			objects:    []runtime.Object{newSecret("private-registry"), newSecret(workspaceSecret)},
+			want:       "private-registry",
+		},
+		{
+			name:       fmt.Sprintf("Configured pull secret that doesn't exist"),
+			pullSecret: "private-registry",
+			objects:    []runtime.Object{newSecret(workspaceSecret)},
+			wantErr:    true,
+		},
+		{
+			name:    fmt.Sprintf("Che workspace registry secret"),
+			objects: []runtime.Object{newSecret(workspaceSecret)},
+			want:    workspaceSecret,
+		},
+		{
+			name:    fmt.Sprintf("No pull secret"),
+			objects: []runtime.Object{},
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.objects...)
+			pullSecret, err := ResolvePullSecret(clientset, "default", codewindInstance.WorkspaceID, tt.pullSecret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolvePullSecret returned error %v, expected error: %v", err, tt.wantErr)
+			}
+			if pullSecret != tt.want {
+				t.Errorf("ResolvePullSecret returned %q, expected %q", pullSecret, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchServiceAccount(t *testing.T) {
+	codewindInstance := setupCodewind()
+
+	tests := []struct {
+		name     string
+		existing []corev1.LocalObjectReference
+		want     []string
+	}{
+		{
+			name: fmt.Sprintf("Pull secret is added to a service account without secrets"),
+			want: []string{codewindInstance.PullSecret},
+		},
+		{
+			name:     fmt.Sprintf("Pull secret is added after the existing secrets"),
+			existing: []corev1.LocalObjectReference{{Name: "other-registry"}},
+			want:     []string{"other-registry", codewindInstance.PullSecret},
+		},
+		{
+			name:     fmt.Sprintf("Pull secret isn't added twice"),
+			existing: []corev1.LocalObjectReference{{Name: codewindInstance.PullSecret}, {Name: "other-registry"}},
+			want:     []string{codewindInstance.PullSecret, "other-registry"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serviceAccount := &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      codewindInstance.ServiceAccountName,
+					Namespace: codewindInstance.Namespace,
+				},
+				ImagePullSecrets: tt.existing,
+			}
+			clientset := fake.NewSimpleClientset(serviceAccount)
+			err := PatchServiceAccount(clientset, codewindInstance)
+			if err != nil {
+				t.Fatalf("PatchServiceAccount returned error %v", err)
+			}
+			patched, _ := clientset.CoreV1().ServiceAccounts(codewindInstance.Namespace).Get(codewindInstance.ServiceAccountName, metav1.GetOptions{})
+			secrets := []string{}
+			for _, secret := range patched.ImagePullSecrets {
+				secrets = append(secrets, secret.Name)
+			}
+			if fmt.Sprint(secrets) != fmt.Sprint(tt.want) {
+				t.Errorf("Service account has image pull secrets %v, expected %v", secrets, tt.want)
+			}
+		})
+	}
+}
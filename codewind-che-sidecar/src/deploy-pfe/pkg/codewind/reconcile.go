@@ -0,0 +1,301 @@
+package codewind
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	log "github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "github.com/openshift/api/route/v1"
+	routev1 "github.com/openshift/client-go/route/clientset/versioned/typed/route/v1"
+)
+
+// lastAppliedAnnotation records the labels, annotations and spec that we last generated for an object. It tells the
+// fields we stopped generating (which are removed from the object) apart from the labels and annotations added by
+// others (which are left alone)
+const lastAppliedAnnotation = "codewind.eclipse.org/last-applied"
+
+// appliedFields are the fields of an object that we generate, as recorded in its last applied annotation. The spec
+// is recorded as a hash, as we always replace the whole spec
+type appliedFields struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	SpecHash    string            `json:"specHash"`
+}
+
+// reconcileService creates the given service if it doesn't exist yet, or updates the existing service if it
+// has drifted from what we want to deploy. A created service is recorded in the rollback
+func reconcileService(clientset kubernetes.Interface, service corev1.Service, rollback *Rollback) error {
+	var err error
+	service.Annotations, err = withLastApplied(service.ObjectMeta, service.Spec)
+	if err != nil {
+		return err
+	}
+	services := clientset.CoreV1().Services(service.GetNamespace())
+	existing, err := services.Get(service.GetName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = services.Create(&service)
+		if err == nil {
+			log.Infof("Created service %s\n", service.GetName())
+			rollback.record("service", service.GetName(), func() error {
+				return services.Delete(service.GetName(), rollbackDeleteOptions())
+			})
+		}
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if !serviceNeedsUpdate(*existing, service) {
+		log.Infof("Service %s is up to date\n", service.GetName())
+		return nil
+	}
+
+	// Labels and annotations are merged, as cloud providers annotate services with their own status (such as GCE NEGs)
+	updated := existing.DeepCopy()
+	updated.Labels, updated.Annotations = mergeObjectMeta(existing.ObjectMeta, service.ObjectMeta)
+	updated.OwnerReferences = service.OwnerReferences
+	updated.Spec = service.Spec
+
+	// The cluster IP is allocated by Kubernetes and can't be changed, so carry it over from the existing service.
+	// Node ports are carried over as well, rather than having new ones allocated on every update
+	updated.Spec.ClusterIP = existing.Spec.ClusterIP
+	if updated.Spec.Type != corev1.ServiceTypeClusterIP {
+		for i := range updated.Spec.Ports {
+			for _, port := range existing.Spec.Ports {
+				if updated.Spec.Ports[i].NodePort == 0 && port.Name == updated.Spec.Ports[i].Name {
+					updated.Spec.Ports[i].NodePort = port.NodePort
+				}
+			}
+		}
+	}
+	_, err = services.Update(updated)
+	if err == nil {
+		log.Infof("Updated service %s\n", service.GetName())
+	}
+	return err
+}
+
+// reconcileDeployment creates the given deployment if it doesn't exist yet, or updates the existing deployment if it
+// has drifted from what we want to deploy (such as after the sidecar image was updated). A created deployment is
+// recorded in the rollback
+func reconcileDeployment(clientset kubernetes.Interface, deploy appsv1.Deployment, rollback *Rollback) error {
+	var err error
+	deploy.Annotations, err = withLastApplied(deploy.ObjectMeta, deploy.Spec)
+	if err != nil {
+		return err
+	}
+	deployments := clientset.AppsV1().Deployments(deploy.GetNamespace())
+	existing, err := deployments.Get(deploy.GetName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = deployments.Create(&deploy)
+		if err == nil {
+			log.Infof("Created deployment %s\n", deploy.GetName())
+			rollback.record("deployment", deploy.GetName(), func() error {
+				return deployments.Delete(deploy.GetName(), rollbackDeleteOptions())
+			})
+		}
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if !deploymentNeedsUpdate(*existing, deploy) {
+		log.Infof("Deployment %s is up to date\n", deploy.GetName())
+		return nil
+	}
+
+	updated := existing.DeepCopy()
+	updated.Labels, updated.Annotations = mergeObjectMeta(existing.ObjectMeta, deploy.ObjectMeta)
+	updated.OwnerReferences = deploy.OwnerReferences
+	updated.Spec = deploy.Spec
+	_, err = deployments.Update(updated)
+	if err == nil {
+		log.Infof("Updated deployment %s\n", deploy.GetName())
+	}
+	return err
+}
+
+// ReconcileRoute creates the Codewind route in the given namespace, or updates the existing one if it has drifted.
+// A created route is recorded in the rollback
+func ReconcileRoute(routeClient routev1.RouteV1Interface, route v1.Route, namespace string, rollback *Rollback) error {
+	var err error
+	route.Annotations, err = withLastApplied(route.ObjectMeta, route.Spec)
+	if err != nil {
+		return err
+	}
+	routes := routeClient.Routes(namespace)
+	existing, err := routes.Get(route.GetName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = routes.Create(&route)
+		if err == nil {
+			log.Infof("Created route %s\n", route.GetName())
+			rollback.record("route", route.GetName(), func() error {
+				return routes.Delete(route.GetName(), rollbackDeleteOptions())
+			})
+		}
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if !objectMetaNeedsUpdate(existing.ObjectMeta, route.ObjectMeta) &&
+		equality.Semantic.DeepDerivative(route.Spec, existing.Spec) {
+		log.Infof("Route %s is up to date\n", route.GetName())
+		return nil
+	}
+
+	updated := existing.DeepCopy()
+	updated.Labels, updated.Annotations = mergeObjectMeta(existing.ObjectMeta, route.ObjectMeta)
+	updated.OwnerReferences = route.OwnerReferences
+	updated.Spec = route.Spec
+	_, err = routes.Update(updated)
+	if err == nil {
+		log.Infof("Updated route %s\n", route.GetName())
+	}
+	return err
+}
+
+// reconcileUnstructured creates the given object (such as an ingress or Gateway API route, whose types we don't build
+// against) if it doesn't exist yet, or updates the existing object if its metadata or spec has drifted. A created
+// object is recorded in the rollback
+func reconcileUnstructured(resources dynamic.ResourceInterface, object *unstructured.Unstructured, rollback *Rollback) error {
+	object = object.DeepCopy()
+	desiredMeta := metav1.ObjectMeta{Labels: object.GetLabels(), Annotations: object.GetAnnotations(), OwnerReferences: object.GetOwnerReferences()}
+	annotations, err := withLastApplied(desiredMeta, object.Object["spec"])
+	if err != nil {
+		return err
+	}
+	object.SetAnnotations(annotations)
+	desiredMeta.Annotations = annotations
+
+	existing, err := resources.Get(object.GetName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = resources.Create(object, metav1.CreateOptions{})
+		if err == nil {
+			log.Infof("Created %s %s\n", object.GetKind(), object.GetName())
+			rollback.record(object.GetKind(), object.GetName(), func() error {
+				return resources.Delete(object.GetName(), rollbackDeleteOptions())
+			})
+		}
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	existingMeta := metav1.ObjectMeta{Name: existing.GetName(), Labels: existing.GetLabels(), Annotations: existing.GetAnnotations(), OwnerReferences: existing.GetOwnerReferences()}
+	if !objectMetaNeedsUpdate(existingMeta, desiredMeta) &&
+		equality.Semantic.DeepDerivative(object.Object["spec"], existing.Object["spec"]) {
+		log.Infof("%s %s is up to date\n", object.GetKind(), object.GetName())
+		return nil
+	}
+
+	updated := existing.DeepCopy()
+	labels, annotations := mergeObjectMeta(existingMeta, desiredMeta)
+	updated.SetLabels(labels)
+	updated.SetAnnotations(annotations)
+	updated.SetOwnerReferences(object.GetOwnerReferences())
+	updated.Object["spec"] = object.Object["spec"]
+	_, err = resources.Update(updated, metav1.UpdateOptions{})
+	if err == nil {
+		log.Infof("Updated %s %s\n", object.GetKind(), object.GetName())
+	}
+	return err
+}
+
+// serviceNeedsUpdate compares an existing service against the one we would generate. Changes to the generated spec
+// are found through the last applied annotation, and fields that Kubernetes defaults on the server (such as the cluster
+// IP or port protocols) are ignored when they aren't set in `desired`
+func serviceNeedsUpdate(existing corev1.Service, desired corev1.Service) bool {
+	return objectMetaNeedsUpdate(existing.ObjectMeta, desired.ObjectMeta) ||
+		!equality.Semantic.DeepDerivative(desired.Spec, existing.Spec)
+}
+
+// deploymentNeedsUpdate compares an existing deployment against the one we would generate. Changes to the generated
+// spec are found through the last applied annotation, and fields that Kubernetes defaults on the server (such as the
+// rollout strategy or termination message paths) are ignored when they aren't set in `desired`
+func deploymentNeedsUpdate(existing appsv1.Deployment, desired appsv1.Deployment) bool {
+	return objectMetaNeedsUpdate(existing.ObjectMeta, desired.ObjectMeta) ||
+		!equality.Semantic.DeepDerivative(desired.Spec, existing.Spec)
+}
+
+// objectMetaNeedsUpdate returns true if the labels, annotations or owner references we generate differ from the ones
+// on the existing object. As the generated annotations include the last applied annotation, this is also true when
+// anything we generate has changed since it was last applied. Labels and annotations that we don't generate are ignored
+func objectMetaNeedsUpdate(existing metav1.ObjectMeta, desired metav1.ObjectMeta) bool {
+	for key, value := range desired.Labels {
+		if existingValue, ok := existing.Labels[key]; !ok || existingValue != value {
+			return true
+		}
+	}
+	for key, value := range desired.Annotations {
+		if existingValue, ok := existing.Annotations[key]; !ok || existingValue != value {
+			return true
+		}
+	}
+	return !equality.Semantic.DeepEqual(existing.OwnerReferences, desired.OwnerReferences)
+}
+
+// withLastApplied returns the annotations of the given object metadata, with the last applied annotation recording
+// them along with the labels and spec
+func withLastApplied(meta metav1.ObjectMeta, spec interface{}) (map[string]string, error) {
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(specJSON)
+	applied, err := json.Marshal(appliedFields{
+		Labels:      meta.Labels,
+		Annotations: meta.Annotations,
+		SpecHash:    hex.EncodeToString(hash[:]),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := map[string]string{}
+	for key, value := range meta.Annotations {
+		annotations[key] = value
+	}
+	annotations[lastAppliedAnnotation] = string(applied)
+	return annotations, nil
+}
+
+// mergeObjectMeta returns the labels and annotations to update an existing object with: the ones we generate, plus
+// the ones on the existing object that others added. The ones we generated before but no longer do are removed
+func mergeObjectMeta(existing metav1.ObjectMeta, desired metav1.ObjectMeta) (map[string]string, map[string]string) {
+	// Objects from before the last applied annotation was added keep all their labels and annotations
+	var lastApplied appliedFields
+	if applied, ok := existing.Annotations[lastAppliedAnnotation]; ok {
+		if err := json.Unmarshal([]byte(applied), &lastApplied); err != nil {
+			log.Warnf("Ignoring the invalid %s annotation on %s: %v\n", lastAppliedAnnotation, existing.Name, err)
+		}
+	}
+	return mergeOwned(existing.Labels, lastApplied.Labels, desired.Labels),
+		mergeOwned(existing.Annotations, lastApplied.Annotations, desired.Annotations)
+}
+
+// mergeOwned merges the desired keys into the existing ones, removing the keys that were last applied but are no
+// longer desired
+func mergeOwned(existing map[string]string, lastApplied map[string]string, desired map[string]string) map[string]string {
+	merged := map[string]string{}
+	for key, value := range existing {
+		if _, owned := lastApplied[key]; !owned {
+			merged[key] = value
+		}
+	}
+	for key, value := range desired {
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,259 @@
+package codewind
+
+import (
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDeploymentNeedsUpdate(t *testing.T) {
+	codewindInstance := setupCodewind()
+	desired := createPFEDeploy(codewindInstance)
+
+	// Simulate the fields that Kubernetes defaults when a deployment is created
+	defaulted := createPFEDeploy(codewindInstance)
+	defaulted.Spec.RevisionHistoryLimit = new(int32)
+	defaulted.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyAlways
+	defaulted.Spec.Template.Spec.Containers[0].TerminationMessagePath = "/dev/termination-log"
+	defaulted.Spec.Template.Spec.Containers[0].Ports[0].Protocol = corev1.ProtocolTCP
+	defaulted.Spec.Template.Spec.Containers[0].ReadinessProbe.SuccessThreshold = 1
+	defaulted.Spec.Template.Spec.Containers[0].LivenessProbe.SuccessThreshold = 1
+	defaulted.Annotations = map[string]string{"deployment.kubernetes.io/revision": "1"}
+
+	newImage := createPFEDeploy(codewindInstance)
+	newImage.Spec.Template.Spec.Containers[0].Image = "eclipse/codewind-pfe-amd64:0.9.0"
+
+	newOwner := createPFEDeploy(codewindInstance)
+	newOwner.OwnerReferences[0].UID = "2ef5a2e4-ba4f-11e9-ac2a-005056a04e5e"
+
+	tests := []struct {
+		name        string
+		existing    appsv1.Deployment
+		needsUpdate bool
+	}{
+		{
+			name:        fmt.Sprintf("Identical deployment is left alone"),
+			existing:    desired,
+			needsUpdate: false,
+		},
+		{
+			name:        fmt.Sprintf("Server-side defaults are ignored"),
+			existing:    defaulted,
+			needsUpdate: false,
+		},
+		{
+			name:        fmt.Sprintf("Changed image is updated"),
+			existing:    newImage,
+			needsUpdate: true,
+		},
+		{
+			name:        fmt.Sprintf("Changed owner reference is updated"),
+			existing:    newOwner,
+			needsUpdate: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			needsUpdate := deploymentNeedsUpdate(tt.existing, desired)
+			if needsUpdate != tt.needsUpdate {
+				t.Errorf("deploymentNeedsUpdate returned %v, expected %v", needsUpdate, tt.needsUpdate)
+			}
+		})
+	}
+}
+
+func TestServiceNeedsUpdate(t *testing.T) {
+	codewindInstance := setupCodewind()
+	desired := createPFEService(codewindInstance)
+
+	// Simulate the fields that Kubernetes defaults when a service is created
+	defaulted := createPFEService(codewindInstance)
+	defaulted.Spec.ClusterIP = "172.21.12.4"
+	defaulted.Spec.Type = corev1.ServiceTypeClusterIP
+	defaulted.Spec.Ports[0].Protocol = corev1.ProtocolTCP
+	defaulted.Spec.Ports[0].TargetPort = intstr.FromInt(int(defaulted.Spec.Ports[0].Port))
+
+	newLabels := createPFEService(codewindInstance)
+	newLabels.Labels = map[string]string{"app": "codewind-pfe"}
+
+	foreignLabels := createPFEService(codewindInstance)
+	foreignLabels.Labels = map[string]string{"app": "codewind-pfe", "codewindWorkspace": codewindInstance.WorkspaceID, "team": "tools"}
+
+	tests := []struct {
+		name        string
+		existing    corev1.Service
+		needsUpdate bool
+	}{
+		{
+			name:        fmt.Sprintf("Identical service is left alone"),
+			existing:    desired,
+			needsUpdate: false,
+		},
+		{
+			name:        fmt.Sprintf("Server-side defaults are ignored"),
+			existing:    defaulted,
+			needsUpdate: false,
+		},
+		{
+			name:        fmt.Sprintf("Changed labels are updated"),
+			existing:    newLabels,
+			needsUpdate: true,
+		},
+		{
+			name:        fmt.Sprintf("Labels added by others are left alone"),
+			existing:    foreignLabels,
+			needsUpdate: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			needsUpdate := serviceNeedsUpdate(tt.existing, desired)
+			if needsUpdate != tt.needsUpdate {
+				t.Errorf("serviceNeedsUpdate returned %v, expected %v", needsUpdate, tt.needsUpdate)
+			}
+		})
+	}
+}
+
+func TestReconcileServiceExposure(t *testing.T) {
+	codewindInstance := setupCodewind()
+	codewindInstance.Exposure = ExposureNodePort
+	clientset := fake.NewSimpleClientset()
+
+	// Simulate the node port that Kubernetes allocates when the service is created
+	service := createPFEService(codewindInstance)
+	service.Spec.Ports[0].NodePort = 30191
+	_, err := clientset.CoreV1().Services(service.Namespace).Create(&service)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Switching to a load balancer keeps the allocated node port
+	codewindInstance.Exposure = ExposureLoadBalancer
+	err = reconcileService(clientset, createPFEService(codewindInstance), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, _ := clientset.CoreV1().Services(service.Namespace).Get(service.Name, metav1.GetOptions{})
+	if updated.Spec.Type != corev1.ServiceTypeLoadBalancer || updated.Spec.Ports[0].NodePort != 30191 {
+		t.Errorf("Service was a %s on node port %v, expected a %s on node port %v", updated.Spec.Type, updated.Spec.Ports[0].NodePort, corev1.ServiceTypeLoadBalancer, 30191)
+	}
+
+	// Switching to internal only exposure drops the node port
+	codewindInstance.Exposure = ExposureNone
+	err = reconcileService(clientset, createPFEService(codewindInstance), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, _ = clientset.CoreV1().Services(service.Namespace).Get(service.Name, metav1.GetOptions{})
+	if updated.Spec.Type != corev1.ServiceTypeClusterIP || updated.Spec.Ports[0].NodePort != 0 {
+		t.Errorf("Service was a %s on node port %v, expected a %s without a node port", updated.Spec.Type, updated.Spec.Ports[0].NodePort, corev1.ServiceTypeClusterIP)
+	}
+}
+
+func TestReconcileRemovedFields(t *testing.T) {
+	sharedVolume := setupCodewind()
+	sharedVolume.ShareWorkspaceVolume = true
+	sharedVolume.WorkspaceVolume = &WorkspaceVolume{ClaimName: "claim-che-workspace", SubPath: sharedVolume.WorkspaceID + "/projects"}
+
+	gce := setupCodewind()
+	gce.IngressProfile = IngressProfileGCE
+
+	noPullSecret := setupCodewind()
+	noPullSecret.PullSecret = ""
+
+	traefik := setupCodewind()
+	traefik.IngressProfile = IngressProfileTraefik
+
+	tests := []struct {
+		name    string
+		initial Codewind
+		changed Codewind
+	}{
+		{
+			name:    fmt.Sprintf("Dropped pull secret is removed"),
+			initial: setupCodewind(),
+			changed: noPullSecret,
+		},
+		{
+			name:    fmt.Sprintf("Turning off the shared workspace volume removes its volume and env vars"),
+			initial: sharedVolume,
+			changed: setupCodewind(),
+		},
+		{
+			name:    fmt.Sprintf("Changed ingress profile removes the old service annotations"),
+			initial: gce,
+			changed: traefik,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			desiredDeploy := createPFEDeploy(tt.changed)
+			desiredService := createPFEService(tt.changed)
+			err := reconcileDeployment(clientset, createPFEDeploy(tt.initial), nil)
+			if err == nil {
+				err = reconcileService(clientset, createPFEService(tt.initial), nil)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Others label and annotate the objects too, which has to be kept
+			deploy, _ := clientset.AppsV1().Deployments(desiredDeploy.Namespace).Get(desiredDeploy.Name, metav1.GetOptions{})
+			deploy.Labels["team"] = "tools"
+			deploy.Annotations["deployment.kubernetes.io/revision"] = "1"
+			clientset.AppsV1().Deployments(deploy.Namespace).Update(deploy)
+			service, _ := clientset.CoreV1().Services(desiredService.Namespace).Get(desiredService.Name, metav1.GetOptions{})
+			service.Annotations["cloud.google.com/neg-status"] = `{"network_endpoint_groups":{}}`
+			clientset.CoreV1().Services(service.Namespace).Update(service)
+
+			err = reconcileDeployment(clientset, createPFEDeploy(tt.changed), nil)
+			if err == nil {
+				err = reconcileService(clientset, createPFEService(tt.changed), nil)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The generated fields converge, while the ones added by others are kept
+			deploy, _ = clientset.AppsV1().Deployments(desiredDeploy.Namespace).Get(desiredDeploy.Name, metav1.GetOptions{})
+			if !equality.Semantic.DeepEqual(deploy.Spec, desiredDeploy.Spec) {
+				t.Errorf("Deployment spec is %v, expected %v", deploy.Spec, desiredDeploy.Spec)
+			}
+			if deploy.Labels["team"] != "tools" || deploy.Annotations["deployment.kubernetes.io/revision"] != "1" {
+				t.Errorf("Deployment lost the labels and annotations added by others: %v %v", deploy.Labels, deploy.Annotations)
+			}
+
+			service, _ = clientset.CoreV1().Services(desiredService.Namespace).Get(desiredService.Name, metav1.GetOptions{})
+			for key := range service.Annotations {
+				if _, ok := desiredService.Annotations[key]; !ok && key != lastAppliedAnnotation && key != "cloud.google.com/neg-status" {
+					t.Errorf("Service kept the stale annotation %s", key)
+				}
+			}
+			if service.Annotations["cloud.google.com/neg-status"] == "" {
+				t.Errorf("Service lost the annotation added by others")
+			}
+
+			// Once converged, reconciling again doesn't update anything
+			updates := 0
+			clientset.PrependReactor("update", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				updates++
+				return false, nil, nil
+			})
+			reconcileDeployment(clientset, createPFEDeploy(tt.changed), nil)
+			reconcileService(clientset, createPFEService(tt.changed), nil)
+			if updates != 0 {
+				t.Errorf("Reconciling converged objects made %v updates, expected none", updates)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package codewind
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	types "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// RenderManifests returns every object that deploy-pfe would apply for the given Codewind instance, in the order
+// they're applied: the PFE volume, PFE service & deployment, Performance dashboard service & deployment, and the
+// route, ingress or Gateway API route exposing Codewind (if any, depending on the exposure strategy)
+func RenderManifests(codewind Codewind, storageClass string, wsPVCName string, wsPVCUID types.UID) []runtime.Object {
+	pvc := generatePVC(codewind, volumeSizeOf(codewind), storageClass, wsPVCName, wsPVCUID)
+	pvc.Namespace = codewind.Namespace
+	pfeService := createPFEService(codewind)
+	pfeDeploy := createPFEDeploy(codewind)
+	performanceService := createPerformanceService(codewind)
+	performanceDeploy := createPerformanceDeploy(codewind)
+
+	objects := []runtime.Object{&pvc, &pfeService, &pfeDeploy, &performanceService, &performanceDeploy}
+	if codewind.WorkspaceVolume != nil {
+		// PFE mounts the Che workspace PVC, which is managed by Che
+		objects = objects[1:]
+	}
+	switch exposureOf(codewind) {
+	case ExposureRoute:
+		route := CreateRoute(codewind)
+		route.Namespace = codewind.Namespace
+		objects = append(objects, &route)
+	case ExposureIngress:
+		objects = append(objects, CreateIngress(codewind))
+	case ExposureGateway:
+		objects = append(objects, CreateGatewayRoute(codewind))
+	}
+	return objects
+}
+
+// WriteManifests writes the given objects to `w` as a multi-document stream in the given format, either "yaml"
+// (documents separated by `---`) or "json" (one document after the other)
+func WriteManifests(w io.Writer, objects []runtime.Object, format string) error {
+	for i, object := range objects {
+		var b []byte
+		var err error
+		switch format {
+		case "yaml":
+			b, err = yaml.Marshal(object)
+			if err == nil && i > 0 {
+				_, err = io.WriteString(w, "---\n")
+			}
+		case "json":
+			b, err = json.MarshalIndent(object, "", "  ")
+			b = append(b, '\n')
+		default:
+			return fmt.Errorf("unsupported output format %q, expected yaml or json", format)
+		}
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
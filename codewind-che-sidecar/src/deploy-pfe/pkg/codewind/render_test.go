@@ -0,0 +1,139 @@
+package codewind
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestWriteManifests(t *testing.T) {
+	codewindInstance := setupCodewind()
+	tests := []struct {
+		name        string
+		onOpenShift bool
+		exposure    ExposureStrategy
+		format      string
+		expected    string
+		objects     int
+	}{
+		{
+			name:     fmt.Sprintf("Render manifests as YAML, with an ingress"),
+			format:   "yaml",
+			expected: "kind: Ingress",
+			objects:  6,
+		},
+		{
+			name:        fmt.Sprintf("Render manifests as JSON, with a route"),
+			onOpenShift: true,
+			format:      "json",
+			expected:    `"kind": "Route"`,
+			objects:     6,
+		},
+		{
+			name:     fmt.Sprintf("Render manifests as YAML, with a Gateway API route"),
+			exposure: ExposureGateway,
+			format:   "yaml",
+			expected: "kind: TLSRoute",
+			objects:  6,
+		},
+		{
+			name:     fmt.Sprintf("Render manifests as YAML, with a NodePort service"),
+			exposure: ExposureNodePort,
+			format:   "yaml",
+			expected: "type: NodePort",
+			objects:  5,
+		},
+		{
+			name:     fmt.Sprintf("Render manifests as YAML, without exposing Codewind"),
+			exposure: ExposureNone,
+			format:   "yaml",
+			expected: "type: ClusterIP",
+			objects:  5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codewindInstance.OnOpenShift = tt.onOpenShift
+			codewindInstance.Exposure = tt.exposure
+			objects := RenderManifests(codewindInstance, "", "claim-che-workspace", "")
+			if len(objects) != tt.objects {
+				t.Errorf("Rendered %v objects, expected %v", len(objects), tt.objects)
+			}
+
+			var out bytes.Buffer
+			err := WriteManifests(&out, objects, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Verify every object was written, with its namespace set
+			manifests := out.String()
+			if tt.format == "yaml" && strings.Count(manifests, "---\n") != len(objects)-1 {
+				t.Errorf("YAML stream doesn't contain %v documents", len(objects))
+			}
+			if !strings.Contains(manifests, tt.expected) {
+				t.Errorf("Rendered manifests don't contain the expected route, ingress or Gateway API route")
+			}
+			if strings.Count(manifests, codewindInstance.Namespace) < len(objects) {
+				t.Errorf("Rendered manifests don't all have their namespace set to %v", codewindInstance.Namespace)
+			}
+		})
+	}
+}
+
+// TestRenderPVCOwnerReference verifies that the PVC is only owned by the workspace PVC when its UID is known, as the
+// API server rejects owner references without a UID
+func TestRenderPVCOwnerReference(t *testing.T) {
+	tests := []struct {
+		name   string
+		uid    types.UID
+		owners int
+	}{
+		{
+			name:   fmt.Sprintf("Render the PVC owned by the workspace PVC"),
+			uid:    "b4b7a5ab-ba20-11e9-ac2a-005056a04e5e",
+			owners: 1,
+		},
+		{
+			name:   fmt.Sprintf("Render the PVC without an owner if the workspace PVC UID isn't known"),
+			uid:    "",
+			owners: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := RenderManifests(setupCodewind(), "", "claim-che-workspace", tt.uid)
+			pvc, ok := objects[0].(*corev1.PersistentVolumeClaim)
+			if !ok {
+				t.Fatalf("First rendered object is a %T, expected the PVC", objects[0])
+			}
+			if len(pvc.OwnerReferences) != tt.owners {
+				t.Fatalf("PVC has %v owner references, expected %v", len(pvc.OwnerReferences), tt.owners)
+			}
+			if tt.owners > 0 && pvc.OwnerReferences[0].UID != tt.uid {
+				t.Errorf("PVC is owned by %v, expected %v", pvc.OwnerReferences[0].UID, tt.uid)
+			}
+
+			var out bytes.Buffer
+			err := WriteManifests(&out, objects, "yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(out.String(), `uid: ""`) {
+				t.Errorf("Rendered manifests contain an owner reference without a UID")
+			}
+		})
+	}
+}
+
+func TestWriteManifestsInvalidFormat(t *testing.T) {
+	objects := RenderManifests(setupCodewind(), "", "claim-che-workspace", "")
+	var out bytes.Buffer
+	if err := WriteManifests(&out, objects, "xml"); err == nil {
+		t.Errorf("Rendering manifests in an unsupported format didn't fail as expected")
+	}
+}
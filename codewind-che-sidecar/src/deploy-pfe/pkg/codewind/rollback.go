@@ -0,0 +1,69 @@
+package codewind
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Rollback records the objects that deploy-pfe created during a run, so that they can be deleted again if the run
+// fails partway through, instead of being left for the next run to trip over. Only objects that were created are
+// recorded: objects that already existed (such as the PVC of a previous run) are never touched, even if they were updated
+type Rollback struct {
+	created []createdObject
+}
+
+// createdObject is an object that was created during a run, and how to delete it
+type createdObject struct {
+	kind   string
+	name   string
+	delete func() error
+}
+
+// NewRollback returns a rollback without any created objects
+func NewRollback() *Rollback {
+	return &Rollback{}
+}
+
+// record adds a created object to the rollback. Nothing is recorded on a nil rollback, such as when rollback is disabled
+func (r *Rollback) record(kind string, name string, delete func() error) {
+	if r == nil {
+		return
+	}
+	r.created = append(r.created, createdObject{kind: kind, name: name, delete: delete})
+}
+
+// Run deletes the objects created during the run in reverse order, logging the result for each of them. Objects that
+// are already gone count as rolled back. The number of objects that couldn't be deleted is returned
+func (r *Rollback) Run() int {
+	if r == nil || len(r.created) == 0 {
+		log.Infoln("Nothing to roll back, no objects were created")
+		return 0
+	}
+
+	log.Infof("Rolling back %d objects created by this deployment...\n", len(r.created))
+	failed := 0
+	for i := len(r.created) - 1; i >= 0; i-- {
+		object := r.created[i]
+		err := object.delete()
+		if errors.IsNotFound(err) {
+			log.Infof("Rolled back %s %s, it was already removed\n", object.kind, object.name)
+		} else if err != nil {
+			log.Errorf("Unable to roll back %s %s: %v\n", object.kind, object.name, err)
+			failed++
+		} else {
+			log.Infof("Rolled back %s %s\n", object.kind, object.name)
+		}
+	}
+	r.created = nil
+	return failed
+}
+
+// rollbackDeleteOptions deletes objects in the background, so that the pods of a deployment are removed along with it
+func rollbackDeleteOptions() *metav1.DeleteOptions {
+	propagation := metav1.DeletePropagationBackground
+	return &metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	}
+}
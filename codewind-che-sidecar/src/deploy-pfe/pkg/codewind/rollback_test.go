@@ -0,0 +1,133 @@
+package codewind
+
+import (
+	"deploy-pfe/pkg/constants"
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// failPerformanceDeployment makes creating the Performance dashboard deployment fail, after the PFE objects were created
+func failPerformanceDeployment(clientset *fake.Clientset) {
+	clientset.PrependReactor("create", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		deploy := action.(k8stesting.CreateAction).GetObject().(metav1.Object)
+		if deploy.GetName() == constants.PerformancePrefix+"-"+setupCodewind().WorkspaceID {
+			return true, nil, fmt.Errorf("deployments.apps is forbidden")
+		}
+		return false, nil, nil
+	})
+}
+
+func TestRollback(t *testing.T) {
+	codewindInstance := setupCodewind()
+	pfeName := constants.PFEPrefix + "-" + codewindInstance.WorkspaceID
+	performanceName := constants.PerformancePrefix + "-" + codewindInstance.WorkspaceID
+
+	tests := []struct {
+		name        string
+		existingPVC bool
+		wantDeleted []string
+	}{
+		{
+			name:        fmt.Sprintf("Objects created by the failed deployment are deleted in reverse order"),
+			wantDeleted: []string{"services/" + performanceName, "deployments/" + pfeName, "services/" + pfeName, "persistentvolumeclaims/" + codewindInstance.PVCName},
+		},
+		{
+			name:        fmt.Sprintf("Existing PVC is kept"),
+			existingPVC: true,
+			wantDeleted: []string{"services/" + performanceName, "deployments/" + pfeName, "services/" + pfeName},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []runtime.Object{setupWorkspacePVC(codewindInstance), setupStorageClass()}
+			if tt.existingPVC {
+				pvc := generatePVC(codewindInstance, constants.PFEVolumeSize, "nfs-client", "claim-che-workspace", "")
+				pvc.Namespace = codewindInstance.Namespace
+				objects = append(objects, &pvc)
+			}
+			clientset := fake.NewSimpleClientset(objects...)
+			failPerformanceDeployment(clientset)
+
+			rollback := NewRollback()
+			err := DeployCodewind(clientset, codewindInstance, codewindInstance.Namespace, rollback)
+			if err == nil {
+				t.Fatalf("Deploy didn't fail when the Performance dashboard deployment couldn't be created")
+			}
+
+			clientset.ClearActions()
+			if failed := rollback.Run(); failed != 0 {
+				t.Errorf("Rollback failed to delete %d objects", failed)
+			}
+			deleted := []string{}
+			for _, action := range clientset.Actions() {
+				if action.GetVerb() == "delete" {
+					deleted = append(deleted, action.GetResource().Resource+"/"+action.(k8stesting.DeleteAction).GetName())
+				}
+			}
+			if fmt.Sprint(deleted) != fmt.Sprint(tt.wantDeleted) {
+				t.Errorf("Rollback deleted %v, expected %v", deleted, tt.wantDeleted)
+			}
+			if _, err := clientset.CoreV1().PersistentVolumeClaims(codewindInstance.Namespace).Get(codewindInstance.PVCName, metav1.GetOptions{}); (err == nil) != tt.existingPVC {
+				t.Errorf("PFE PVC exists after rollback: %v, expected %v", err == nil, tt.existingPVC)
+			}
+		})
+	}
+}
+
+// TestRollbackAnchor verifies that the anchor ConfigMap is rolled back when it was created by the failed deployment,
+// and kept when it already existed
+func TestRollbackAnchor(t *testing.T) {
+	codewindInstance := setupCodewind()
+	anchorName := constants.PFEPrefix + "-" + codewindInstance.WorkspaceID
+	clientset := fake.NewSimpleClientset(setupWorkspacePVC(codewindInstance), setupStorageClass())
+
+	rollback := NewRollback()
+	_, err := GetOwner(clientset, codewindInstance.Namespace, codewindInstance.WorkspaceID, OwnershipConfigMap, rollback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed := rollback.Run(); failed != 0 {
+		t.Errorf("Rollback failed to delete %d objects", failed)
+	}
+	if _, err := clientset.CoreV1().ConfigMaps(codewindInstance.Namespace).Get(anchorName, metav1.GetOptions{}); err == nil {
+		t.Errorf("Anchor ConfigMap created by the failed deployment wasn't rolled back")
+	}
+
+	// An anchor that already existed, such as from a previous deployment, is kept
+	_, err = GetOwner(clientset, codewindInstance.Namespace, codewindInstance.WorkspaceID, OwnershipConfigMap, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rollback = NewRollback()
+	_, err = GetOwner(clientset, codewindInstance.Namespace, codewindInstance.WorkspaceID, OwnershipConfigMap, rollback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rollback.Run()
+	if _, err := clientset.CoreV1().ConfigMaps(codewindInstance.Namespace).Get(anchorName, metav1.GetOptions{}); err != nil {
+		t.Errorf("Existing anchor ConfigMap was rolled back")
+	}
+}
+
+func TestRollbackFailure(t *testing.T) {
+	codewindInstance := setupCodewind()
+	clientset := fake.NewSimpleClientset(setupWorkspacePVC(codewindInstance), setupStorageClass())
+	failPerformanceDeployment(clientset)
+	clientset.PrependReactor("delete", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("services is forbidden")
+	})
+
+	rollback := NewRollback()
+	DeployCodewind(clientset, codewindInstance, codewindInstance.Namespace, rollback)
+	if failed := rollback.Run(); failed != 2 {
+		t.Errorf("Rollback failed to delete %d objects, expected %d", failed, 2)
+	}
+	if _, err := clientset.AppsV1().Deployments(codewindInstance.Namespace).Get(constants.PFEPrefix+"-"+codewindInstance.WorkspaceID, metav1.GetOptions{}); err == nil {
+		t.Errorf("PFE deployment wasn't rolled back after failing to delete a service")
+	}
+}
@@ -0,0 +1,198 @@
+package codewind
+
+import (
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"deploy-pfe/pkg/config"
+	"deploy-pfe/pkg/constants"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+
+	v1 "github.com/openshift/api/route/v1"
+)
+
+// Keys of the route TLS secret. The certificate and key use the same keys as a kubernetes.io/tls secret
+const (
+	routeCertificateKey              = corev1.TLSCertKey
+	routeKeyKey                      = corev1.TLSPrivateKeyKey
+	routeCACertificateKey            = "ca.crt"
+	routeDestinationCACertificateKey = "destination-ca.crt"
+)
+
+// RouteSettings represents how the Codewind route is terminated and exposed on OpenShift
+type RouteSettings struct {
+	Termination              v1.TLSTerminationType
+	TLSSecret                string
+	Certificate              string
+	Key                      string
+	CACertificate            string
+	DestinationCACertificate string
+	ShardLabels              map[string]string
+	Annotations              map[string]string
+}
+
+// GetRouteSettings returns the route settings: the termination (edge, reencrypt or passthrough), the TLS secret holding
+// the route certificates, the shard labels (such as "router=internal") that select the router shard, and the extra route
+// annotations. The settings are validated against each other, while the certificates are only loaded from the secret
+// by LoadRouteCertificates
+func GetRouteSettings(settings *config.Config) (RouteSettings, error) {
+	route := RouteSettings{
+		Termination: v1.TLSTerminationType(settings.Route.Termination),
+		TLSSecret:   settings.Route.TLSSecret,
+		ShardLabels: map[string]string{},
+		Annotations: map[string]string{},
+	}
+	if route.Termination == "" {
+		route.Termination = constants.RouteTermination
+	}
+
+	if value := settings.Route.ShardLabels; value != "" {
+		shardLabels, err := labels.ConvertSelectorToLabelsMap(value)
+		if err != nil {
+			return RouteSettings{}, fmt.Errorf("invalid value %q for route.shardLabels, expected key=value pairs separated by commas: %v", value, err)
+		}
+		route.ShardLabels = shardLabels
+	}
+	for key, value := range settings.Route.Annotations {
+		route.Annotations[key] = value
+	}
+
+	return route, validateRouteSettings(route)
+}
+
+// validateRouteSettings checks that the termination mode, TLS secret and shard labels of the route fit together
+func validateRouteSettings(settings RouteSettings) error {
+	switch settings.Termination {
+	case v1.TLSTerminationPassthrough:
+		// The router doesn't terminate TLS for passthrough routes, so can't use any certificates
+		if settings.TLSSecret != "" {
+			return fmt.Errorf("a route TLS secret can't be used with %s termination, as TLS is terminated by Codewind itself", settings.Termination)
+		}
+	case v1.TLSTerminationEdge:
+		// The router connects to Codewind over plain HTTP, which PFE then serves instead of HTTPS
+	case v1.TLSTerminationReencrypt:
+		// The router has to trust the self-signed certificate of Codewind to re-encrypt traffic to it
+		if settings.TLSSecret == "" {
+			return fmt.Errorf("%s termination needs a route TLS secret holding the %s that Codewind's certificate is verified against", settings.Termination, routeDestinationCACertificateKey)
+		}
+	default:
+		return fmt.Errorf("invalid route termination %q, expected %s, %s or %s", settings.Termination,
+			v1.TLSTerminationEdge, v1.TLSTerminationReencrypt, v1.TLSTerminationPassthrough)
+	}
+
+	for key, value := range settings.ShardLabels {
+		if key == "app" || key == "codewindWorkspace" {
+			return fmt.Errorf("route shard label %s can't be used, as it's set by Codewind", key)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid route shard label key %q: %s", key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid route shard label value %q: %s", value, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// LoadRouteCertificates loads the route certificates from the TLS secret of the route settings (if any) in the given
+// namespace, and checks that the certificates are complete for the termination mode
+func LoadRouteCertificates(clientset kubernetes.Interface, namespace string, settings *RouteSettings) error {
+	if settings.TLSSecret == "" {
+		return nil
+	}
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(settings.TLSSecret, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to retrieve route TLS secret %s: %v", settings.TLSSecret, err)
+	}
+
+	settings.Certificate = string(secret.Data[routeCertificateKey])
+	settings.Key = string(secret.Data[routeKeyKey])
+	settings.CACertificate = string(secret.Data[routeCACertificateKey])
+	settings.DestinationCACertificate = string(secret.Data[routeDestinationCACertificateKey])
+
+	if (settings.Certificate == "") != (settings.Key == "") {
+		return fmt.Errorf("route TLS secret %s must hold both %s and %s, or neither to use the router's default certificate", settings.TLSSecret, routeCertificateKey, routeKeyKey)
+	}
+	if settings.CACertificate != "" && settings.Certificate == "" {
+		return fmt.Errorf("route TLS secret %s holds %s without a %s", settings.TLSSecret, routeCACertificateKey, routeCertificateKey)
+	}
+	if settings.Termination == v1.TLSTerminationReencrypt && settings.DestinationCACertificate == "" {
+		return fmt.Errorf("route TLS secret %s must hold %s for %s termination", settings.TLSSecret, routeDestinationCACertificateKey, settings.Termination)
+	}
+	if settings.Termination != v1.TLSTerminationReencrypt && settings.DestinationCACertificate != "" {
+		return fmt.Errorf("route TLS secret %s holds %s, which can only be used with %s termination", settings.TLSSecret, routeDestinationCACertificateKey, v1.TLSTerminationReencrypt)
+	}
+
+	for key, value := range map[string]string{
+		routeCertificateKey:              settings.Certificate,
+		routeKeyKey:                      settings.Key,
+		routeCACertificateKey:            settings.CACertificate,
+		routeDestinationCACertificateKey: settings.DestinationCACertificate,
+	} {
+		if block, _ := pem.Decode([]byte(value)); value != "" && block == nil {
+			return fmt.Errorf("%s in route TLS secret %s is not PEM encoded", key, settings.TLSSecret)
+		}
+	}
+	return nil
+}
+
+// CreateRoute returns an OpenShift route for the Codewind PFE service
+func CreateRoute(codewind Codewind) v1.Route {
+	labels := map[string]string{}
+	for key, value := range codewind.Route.ShardLabels {
+		labels[key] = value
+	}
+	labels["app"] = constants.PFEPrefix
+	labels["codewindWorkspace"] = codewind.WorkspaceID
+
+	var annotations map[string]string
+	if len(codewind.Route.Annotations) > 0 {
+		annotations = codewind.Route.Annotations
+	}
+
+	termination := codewind.Route.Termination
+	if termination == "" {
+		termination = constants.RouteTermination
+	}
+
+	weight := int32(100)
+
+	return v1.Route{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Route",
+			APIVersion: "route.openshift.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            constants.PFEPrefix + "-" + codewind.WorkspaceID,
+			Labels:          labels,
+			Annotations:     annotations,
+			OwnerReferences: ownerReferences(codewind),
+		},
+		Spec: v1.RouteSpec{
+			Host: codewind.Ingress,
+			Port: &v1.RoutePort{
+				TargetPort: intstr.FromInt(pfePort(codewind)),
+			},
+			TLS: &v1.TLSConfig{
+				InsecureEdgeTerminationPolicy: v1.InsecureEdgeTerminationPolicyRedirect,
+				Termination:                   termination,
+				Certificate:                   codewind.Route.Certificate,
+				Key:                           codewind.Route.Key,
+				CACertificate:                 codewind.Route.CACertificate,
+				DestinationCACertificate:      codewind.Route.DestinationCACertificate,
+			},
+			To: v1.RouteTargetReference{
+				Kind:   "Service",
+				Name:   constants.PFEPrefix + "-" + codewind.WorkspaceID,
+				Weight: &weight,
+			},
+		},
+	}
+}
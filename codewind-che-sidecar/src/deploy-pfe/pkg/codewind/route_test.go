@@ -0,0 +1,190 @@
+package codewind
+
+import (
+	"fmt"
+	"testing"
+
+	"deploy-pfe/pkg/config"
+	"deploy-pfe/pkg/constants"
+
+	v1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testPEM = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+
+func TestGetRouteSettings(t *testing.T) {
+	tests := []struct {
+		name        string
+		route       config.RouteConfig
+		termination v1.TLSTerminationType
+		wantErr     bool
+	}{
+		{
+			name:        fmt.Sprintf("Default passthrough termination"),
+			route:       config.Default().Route,
+			termination: v1.TLSTerminationPassthrough,
+		},
+		{
+			name:        fmt.Sprintf("Edge termination with shard labels and annotations"),
+			route:       config.RouteConfig{Termination: "edge", ShardLabels: "router=internal", Annotations: map[string]string{"haproxy.router.openshift.io/timeout": "1h"}},
+			termination: v1.TLSTerminationEdge,
+		},
+		{
+			name:        fmt.Sprintf("Reencrypt termination with a TLS secret"),
+			route:       config.RouteConfig{Termination: "reencrypt", TLSSecret: "codewind-route-tls"},
+			termination: v1.TLSTerminationReencrypt,
+		},
+		{
+			name:    fmt.Sprintf("Reencrypt termination without a TLS secret"),
+			route:   config.RouteConfig{Termination: "reencrypt"},
+			wantErr: true,
+		},
+		{
+			name:    fmt.Sprintf("Passthrough termination with a TLS secret"),
+			route:   config.RouteConfig{Termination: "passthrough", TLSSecret: "codewind-route-tls"},
+			wantErr: true,
+		},
+		{
+			name:    fmt.Sprintf("Invalid termination"),
+			route:   config.RouteConfig{Termination: "none"},
+			wantErr: true,
+		},
+		{
+			name:    fmt.Sprintf("Shard label overriding a Codewind label"),
+			route:   config.RouteConfig{ShardLabels: "app=other"},
+			wantErr: true,
+		},
+		{
+			name:    fmt.Sprintf("Invalid shard label value"),
+			route:   config.RouteConfig{ShardLabels: "router=not a label"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.Default()
+			settings.Route = tt.route
+			route, err := GetRouteSettings(settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !tt.wantErr && route.Termination != tt.termination {
+				t.Errorf("Route termination was %s, expected %s", route.Termination, tt.termination)
+			}
+		})
+	}
+}
+
+func TestLoadRouteCertificates(t *testing.T) {
+	tests := []struct {
+		name        string
+		termination v1.TLSTerminationType
+		data        map[string]string
+		wantErr     bool
+	}{
+		{
+			name:        fmt.Sprintf("Edge termination with a certificate, key and CA"),
+			termination: v1.TLSTerminationEdge,
+			data:        map[string]string{"tls.crt": testPEM, "tls.key": testPEM, "ca.crt": testPEM},
+		},
+		{
+			name:        fmt.Sprintf("Reencrypt termination with the router's default certificate"),
+			termination: v1.TLSTerminationReencrypt,
+			data:        map[string]string{"destination-ca.crt": testPEM},
+		},
+		{
+			name:        fmt.Sprintf("Reencrypt termination without a destination CA"),
+			termination: v1.TLSTerminationReencrypt,
+			data:        map[string]string{"tls.crt": testPEM, "tls.key": testPEM},
+			wantErr:     true,
+		},
+		{
+			name:        fmt.Sprintf("Edge termination with a destination CA"),
+			termination: v1.TLSTerminationEdge,
+			data:        map[string]string{"destination-ca.crt": testPEM},
+			wantErr:     true,
+		},
+		{
+			name:        fmt.Sprintf("Certificate without a key"),
+			termination: v1.TLSTerminationEdge,
+			data:        map[string]string{"tls.crt": testPEM},
+			wantErr:     true,
+		},
+		{
+			name:        fmt.Sprintf("Certificate that isn't PEM encoded"),
+			termination: v1.TLSTerminationEdge,
+			data:        map[string]string{"tls.crt": "certificate", "tls.key": testPEM},
+			wantErr:     true,
+		},
+		{
+			name:        fmt.Sprintf("Missing secret"),
+			termination: v1.TLSTerminationEdge,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			if tt.data != nil {
+				secret := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "codewind-route-tls", Namespace: "che"},
+					Data:       map[string][]byte{},
+				}
+				for key, value := range tt.data {
+					secret.Data[key] = []byte(value)
+				}
+				clientset = fake.NewSimpleClientset(secret)
+			}
+
+			settings := RouteSettings{Termination: tt.termination, TLSSecret: "codewind-route-tls"}
+			err := LoadRouteCertificates(clientset, "che", &settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !tt.wantErr && settings.Certificate != tt.data["tls.crt"] {
+				t.Errorf("Route certificate was %q, expected %q", settings.Certificate, tt.data["tls.crt"])
+			}
+		})
+	}
+}
+
+func TestCreateRoute(t *testing.T) {
+	codewindInstance := setupCodewind()
+	codewindInstance.Route = RouteSettings{
+		Termination:              v1.TLSTerminationReencrypt,
+		DestinationCACertificate: testPEM,
+		ShardLabels:              map[string]string{"router": "internal"},
+		Annotations:              map[string]string{"haproxy.router.openshift.io/timeout": "1h"},
+	}
+	route := CreateRoute(codewindInstance)
+
+	if route.Spec.TLS.Termination != v1.TLSTerminationReencrypt || route.Spec.TLS.DestinationCACertificate != testPEM {
+		t.Errorf("Route TLS was %v, expected reencrypt termination with a destination CA", route.Spec.TLS)
+	}
+	if route.Labels["router"] != "internal" || route.Labels["codewindWorkspace"] != codewindInstance.WorkspaceID {
+		t.Errorf("Route labels were %v, expected the shard and Codewind labels", route.Labels)
+	}
+	if route.Annotations["haproxy.router.openshift.io/timeout"] != "1h" {
+		t.Errorf("Route annotations were %v, expected the route annotations", route.Annotations)
+	}
+
+	// Routes default to passthrough termination
+	route = CreateRoute(setupCodewind())
+	if route.Spec.TLS.Termination != v1.TLSTerminationPassthrough {
+		t.Errorf("Route termination was %s, expected %s", route.Spec.TLS.Termination, v1.TLSTerminationPassthrough)
+	}
+	if route.Spec.Port.TargetPort.IntValue() != constants.PFEContainerPort {
+		t.Errorf("Route targeted port %v, expected %v", route.Spec.Port.TargetPort.IntValue(), constants.PFEContainerPort)
+	}
+
+	// Edge routes connect to the plain HTTP port of PFE
+	codewindInstance.OnOpenShift = true
+	codewindInstance.Route = RouteSettings{Termination: v1.TLSTerminationEdge}
+	route = CreateRoute(codewindInstance)
+	if route.Spec.Port.TargetPort.IntValue() != constants.PFEHTTPPort {
+		t.Errorf("Edge route targeted port %v, expected %v", route.Spec.Port.TargetPort.IntValue(), constants.PFEHTTPPort)
+	}
+}
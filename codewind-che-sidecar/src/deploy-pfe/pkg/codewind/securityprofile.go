@@ -0,0 +1,248 @@
+package codewind
+
+import (
+	"fmt"
+	"strings"
+
+	"deploy-pfe/pkg/config"
+	"deploy-pfe/pkg/constants"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SecurityProfile determines the security context that a Codewind container runs with
+type SecurityProfile string
+
+const (
+	// SecurityProfilePrivileged runs the container privileged, which buildah in PFE needs to build images as root
+	SecurityProfilePrivileged SecurityProfile = "privileged"
+
+	// SecurityProfileRootlessBuildah runs PFE as a non-root user, with only the capabilities and fuse device that
+	// rootless buildah needs to build images in user namespaces. The fuse device is only added by CRI-O
+	SecurityProfileRootlessBuildah SecurityProfile = "rootless-buildah"
+
+	// SecurityProfileRestricted runs the container as a non-root user without any capabilities or privilege
+	// escalation, which the Performance dashboard can run with. It doesn't fully meet the restricted Pod Security
+	// Standard, as the seccomp profile can only be set through the deprecated annotation
+	SecurityProfileRestricted SecurityProfile = "restricted"
+)
+
+// Pod Security Standards levels, as set in the pod-security.kubernetes.io labels of a namespace
+const (
+	podSecurityPrivileged = "privileged"
+	podSecurityBaseline   = "baseline"
+	podSecurityRestricted = "restricted"
+)
+
+// Annotations of the Codewind pods for what can't be set in their security context
+const (
+	// criODevicesAnnotation adds host devices to the containers of a pod on CRI-O, such as OpenShift. Other container
+	// runtimes (such as containerd) ignore it, so don't add the devices
+	criODevicesAnnotation = "io.kubernetes.cri-o.Devices"
+
+	// seccompPodAnnotation sets the seccomp profile of the containers of a pod. It's deprecated in favour of the
+	// seccompProfile field of the security context, which the Kubernetes API used here doesn't have yet. Recent
+	// kubelets ignore the annotation, so the seccomp profile isn't enforced there, and the restricted Pod Security
+	// Standard only accepts the field
+	seccompPodAnnotation = "seccomp.security.alpha.kubernetes.io/pod"
+)
+
+// rootlessBuildahCapabilities are the capabilities that newuidmap and newgidmap need to set up the user namespace of
+// rootless buildah
+var rootlessBuildahCapabilities = []corev1.Capability{"SETUID", "SETGID"}
+
+// GetSecurityProfiles returns the security profiles of PFE (privileged or rootless-buildah) and the Performance
+// dashboard (privileged or restricted)
+func GetSecurityProfiles(settings *config.Config) (SecurityProfile, SecurityProfile, error) {
+	pfeProfile, err := getSecurityProfile("pfe.securityProfile", settings.PFE.SecurityProfile, SecurityProfilePrivileged, SecurityProfileRootlessBuildah)
+	if err != nil {
+		return "", "", err
+	}
+	performanceProfile, err := getSecurityProfile("performance.securityProfile", settings.Performance.SecurityProfile, SecurityProfilePrivileged, SecurityProfileRestricted)
+	if err != nil {
+		return "", "", err
+	}
+	return pfeProfile, performanceProfile, nil
+}
+
+// getSecurityProfile checks that the security profile set with the given config key is one of the allowed profiles
+func getSecurityProfile(key string, value string, allowed ...SecurityProfile) (SecurityProfile, error) {
+	profile := SecurityProfile(value)
+	names := []string{}
+	for _, allowedProfile := range allowed {
+		if profile == allowedProfile {
+			return profile, nil
+		}
+		names = append(names, string(allowedProfile))
+	}
+	return "", fmt.Errorf("invalid security profile %q for %s, expected %s", profile, key, strings.Join(names, " or "))
+}
+
+// securityProfileOf returns the given security profile, defaulting to privileged as Codewind ran before profiles existed
+func securityProfileOf(profile SecurityProfile) SecurityProfile {
+	if profile == "" {
+		return SecurityProfilePrivileged
+	}
+	return profile
+}
+
+// setSecurityProfile sets the security context of the pod template and its first container for a security profile.
+// The user to run as is left to the SCC on OpenShift, which assigns one from the namespace's range
+func setSecurityProfile(template *corev1.PodTemplateSpec, profile SecurityProfile, onOpenShift bool) {
+	container := &template.Spec.Containers[0]
+	privileged := false
+	switch securityProfileOf(profile) {
+	case SecurityProfilePrivileged:
+		privileged = true
+		container.SecurityContext = &corev1.SecurityContext{
+			Privileged: &privileged,
+		}
+		return
+	case SecurityProfileRootlessBuildah:
+		allowPrivilegeEscalation := true
+		container.SecurityContext = &corev1.SecurityContext{
+			Privileged:               &privileged,
+			AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{"ALL"},
+				Add:  rootlessBuildahCapabilities,
+			},
+		}
+		setPodAnnotation(template, criODevicesAnnotation, "/dev/fuse")
+	case SecurityProfileRestricted:
+		allowPrivilegeEscalation := false
+		container.SecurityContext = &corev1.SecurityContext{
+			Privileged:               &privileged,
+			AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{"ALL"},
+			},
+		}
+	}
+
+	runAsNonRoot := true
+	template.Spec.SecurityContext = &corev1.PodSecurityContext{
+		RunAsNonRoot: &runAsNonRoot,
+	}
+	if !onOpenShift {
+		runAsUser := int64(constants.NonRootUID)
+		template.Spec.SecurityContext.RunAsUser = &runAsUser
+	}
+	setPodAnnotation(template, seccompPodAnnotation, "runtime/default")
+}
+
+// setPodAnnotation adds an annotation to the pod template
+func setPodAnnotation(template *corev1.PodTemplateSpec, key string, value string) {
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[key] = value
+}
+
+// PodSecurityError is returned when the PodSecurity admission of the namespace would reject Codewind pods
+type PodSecurityError struct {
+	Namespace  string
+	Level      string
+	Rejections []string
+}
+
+func (e *PodSecurityError) Error() string {
+	return fmt.Sprintf("namespace %s enforces the %s Pod Security Standard, which rejects %s", e.Namespace, e.Level, strings.Join(e.Rejections, "; "))
+}
+
+// CheckPodSecurity checks the security profiles of Codewind against the Pod Security Standard levels set in the
+// labels of the namespace, before anything is created. A *PodSecurityError explaining why is returned if the enforced
+// level rejects a Codewind pod, while the warn and audit levels are only logged. What the security profiles can't
+// enforce on every cluster is logged as well
+func CheckPodSecurity(clientset kubernetes.Interface, namespace string, codewind Codewind) error {
+	deployments := []struct {
+		name    string
+		profile SecurityProfile
+	}{
+		{constants.PFEPrefix, codewind.PFESecurityProfile},
+		{constants.PerformancePrefix, codewind.PerformanceSecurityProfile},
+	}
+	for _, deployment := range deployments {
+		for _, limitation := range securityProfileLimitations(securityProfileOf(deployment.profile), codewind.OnOpenShift) {
+			log.Warnf("%s with security profile %s: %s\n", deployment.name, securityProfileOf(deployment.profile), limitation)
+		}
+	}
+
+	ns, err := clientset.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		log.Warnf("Unable to retrieve namespace %s to check its Pod Security Standards: %v\n", namespace, err)
+		return nil
+	}
+	for _, mode := range []string{"warn", "audit", "enforce"} {
+		level := ns.Labels["pod-security.kubernetes.io/"+mode]
+		if level == "" {
+			continue
+		}
+		rejections := []string{}
+		for _, deployment := range deployments {
+			if reason := podSecurityRejection(level, securityProfileOf(deployment.profile)); reason != "" {
+				rejections = append(rejections, fmt.Sprintf("%s with security profile %s: %s", deployment.name, securityProfileOf(deployment.profile), reason))
+			}
+			if caveat := podSecurityCaveat(level, securityProfileOf(deployment.profile)); caveat != "" {
+				log.Warnf("Namespace %s %ss on the %s Pod Security Standard, which may reject %s with security profile %s: %s\n",
+					namespace, mode, level, deployment.name, securityProfileOf(deployment.profile), caveat)
+			}
+		}
+		if len(rejections) == 0 {
+			continue
+		}
+		if mode == "enforce" {
+			return &PodSecurityError{Namespace: namespace, Level: level, Rejections: rejections}
+		}
+		for _, rejection := range rejections {
+			log.Warnf("Namespace %s %ss on the %s Pod Security Standard, which doesn't allow %s\n", namespace, mode, level, rejection)
+		}
+	}
+	return nil
+}
+
+// podSecurityRejection returns why a Pod Security Standard level rejects pods with the given security profile, or an
+// empty string if it allows them
+func podSecurityRejection(level string, profile SecurityProfile) string {
+	switch level {
+	case podSecurityBaseline, podSecurityRestricted:
+		if profile == SecurityProfilePrivileged {
+			return "privileged containers aren't allowed, use a rootless-buildah or restricted security profile instead"
+		}
+		if level == podSecurityRestricted && profile == SecurityProfileRootlessBuildah {
+			return "privilege escalation and the SETUID and SETGID capabilities aren't allowed, which rootless buildah needs to map users, " +
+				"label the namespace with the baseline level instead"
+		}
+	}
+	return ""
+}
+
+// podSecurityCaveat returns why a Pod Security Standard level may still reject pods with the given security profile,
+// despite allowing their security context, or an empty string if there is no such caveat
+func podSecurityCaveat(level string, profile SecurityProfile) string {
+	if level == podSecurityRestricted && profile == SecurityProfileRestricted {
+		return "the seccomp profile is only set through the deprecated " + seccompPodAnnotation + " annotation, " +
+			"while the restricted level needs the seccompProfile field of the security context"
+	}
+	return ""
+}
+
+// securityProfileLimitations returns what pods with the given security profile may not get on every cluster, whatever
+// its Pod Security Standards
+func securityProfileLimitations(profile SecurityProfile, onOpenShift bool) []string {
+	limitations := []string{}
+	if profile == SecurityProfilePrivileged {
+		return limitations
+	}
+	limitations = append(limitations, "the runtime/default seccomp profile isn't enforced on clusters whose kubelets ignore the deprecated "+
+		seccompPodAnnotation+" annotation, as the seccompProfile field of the security context can't be set yet")
+	if profile == SecurityProfileRootlessBuildah && !onOpenShift {
+		limitations = append(limitations, "the /dev/fuse device is only added by the CRI-O container runtime (through the "+
+			criODevicesAnnotation+" annotation), so rootless buildah can't use fuse-overlayfs on other runtimes such as containerd")
+	}
+	return limitations
+}
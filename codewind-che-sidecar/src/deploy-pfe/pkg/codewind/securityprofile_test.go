@@ -0,0 +1,254 @@
+package codewind
+
+import (
+	"fmt"
+	"testing"
+
+	"deploy-pfe/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSetSecurityProfile(t *testing.T) {
+	tests := []struct {
+		name           string
+		profile        SecurityProfile
+		onOpenShift    bool
+		privileged     bool
+		runAsNonRoot   bool
+		runAsUser      bool
+		capabilities   []corev1.Capability
+		fuseAnnotation bool
+	}{
+		{
+			name:       fmt.Sprintf("Privileged profile by default"),
+			privileged: true,
+		},
+		{
+			name:           fmt.Sprintf("Rootless buildah profile"),
+			profile:        SecurityProfileRootlessBuildah,
+			runAsNonRoot:   true,
+			runAsUser:      true,
+			capabilities:   rootlessBuildahCapabilities,
+			fuseAnnotation: true,
+		},
+		{
+			name:         fmt.Sprintf("Restricted profile"),
+			profile:      SecurityProfileRestricted,
+			runAsNonRoot: true,
+			runAsUser:    true,
+		},
+		{
+			name:         fmt.Sprintf("Restricted profile leaves the user to the SCC on OpenShift"),
+			profile:      SecurityProfileRestricted,
+			onOpenShift:  true,
+			runAsNonRoot: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := createPerformanceDeploy(setupCodewind()).Spec.Template
+			setSecurityProfile(&template, tt.profile, tt.onOpenShift)
+
+			securityContext := template.Spec.Containers[0].SecurityContext
+			if *securityContext.Privileged != tt.privileged {
+				t.Errorf("Container privileged is %v, expected %v", *securityContext.Privileged, tt.privileged)
+			}
+			runAsNonRoot := template.Spec.SecurityContext != nil && *template.Spec.SecurityContext.RunAsNonRoot
+			if runAsNonRoot != tt.runAsNonRoot {
+				t.Errorf("Pod runAsNonRoot is %v, expected %v", runAsNonRoot, tt.runAsNonRoot)
+			}
+			runAsUser := template.Spec.SecurityContext != nil && template.Spec.SecurityContext.RunAsUser != nil
+			if runAsUser != tt.runAsUser {
+				t.Errorf("Pod runAsUser set is %v, expected %v", runAsUser, tt.runAsUser)
+			}
+			if tt.runAsNonRoot && template.Annotations[seccompPodAnnotation] != "runtime/default" {
+				t.Errorf("Pod has seccomp profile %q, expected runtime/default", template.Annotations[seccompPodAnnotation])
+			}
+			if securityContext.Capabilities != nil && fmt.Sprint(securityContext.Capabilities.Add) != fmt.Sprint(tt.capabilities) {
+				t.Errorf("Container adds capabilities %v, expected %v", securityContext.Capabilities.Add, tt.capabilities)
+			}
+			if _, ok := template.Annotations[criODevicesAnnotation]; ok != tt.fuseAnnotation {
+				t.Errorf("Pod fuse device annotation set is %v, expected %v", ok, tt.fuseAnnotation)
+			}
+		})
+	}
+}
+
+func TestCheckPodSecurity(t *testing.T) {
+	rootless := setupCodewind()
+	rootless.PFESecurityProfile = SecurityProfileRootlessBuildah
+	rootless.PerformanceSecurityProfile = SecurityProfileRestricted
+
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		codewind Codewind
+		wantErr  bool
+	}{
+		{
+			name:     fmt.Sprintf("Privileged profile in an unlabeled namespace"),
+			codewind: setupCodewind(),
+		},
+		{
+			name:     fmt.Sprintf("Privileged profile in a baseline namespace"),
+			labels:   map[string]string{"pod-security.kubernetes.io/enforce": "baseline"},
+			codewind: setupCodewind(),
+			wantErr:  true,
+		},
+		{
+			name:     fmt.Sprintf("Privileged profile in a namespace only warning on baseline"),
+			labels:   map[string]string{"pod-security.kubernetes.io/warn": "baseline"},
+			codewind: setupCodewind(),
+		},
+		{
+			name:     fmt.Sprintf("Rootless buildah profile in a baseline namespace"),
+			labels:   map[string]string{"pod-security.kubernetes.io/enforce": "baseline"},
+			codewind: rootless,
+		},
+		{
+			name:     fmt.Sprintf("Rootless buildah profile in a restricted namespace"),
+			labels:   map[string]string{"pod-security.kubernetes.io/enforce": "restricted"},
+			codewind: rootless,
+			wantErr:  true,
+		},
+		{
+			name:     fmt.Sprintf("Rootless buildah profile in a namespace only warning on restricted"),
+			labels:   map[string]string{"pod-security.kubernetes.io/warn": "restricted"},
+			codewind: rootless,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "default",
+					Labels: tt.labels,
+				},
+			}
+			clientset := fake.NewSimpleClientset(namespace)
+			err := CheckPodSecurity(clientset, "default", tt.codewind)
+			if _, ok := err.(*PodSecurityError); ok != tt.wantErr {
+				t.Errorf("CheckPodSecurity returned %v, expected a *PodSecurityError: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestPodSecurityCaveat verifies that the restricted profile isn't claimed to meet the restricted Pod Security
+// Standard, as its seccomp profile can only be set through the deprecated annotation
+func TestPodSecurityCaveat(t *testing.T) {
+	tests := []struct {
+		name       string
+		level      string
+		profile    SecurityProfile
+		wantCaveat bool
+	}{
+		{
+			name:       fmt.Sprintf("Restricted profile on the restricted level"),
+			level:      podSecurityRestricted,
+			profile:    SecurityProfileRestricted,
+			wantCaveat: true,
+		},
+		{
+			name:    fmt.Sprintf("Restricted profile on the baseline level"),
+			level:   podSecurityBaseline,
+			profile: SecurityProfileRestricted,
+		},
+		{
+			name:    fmt.Sprintf("Rootless buildah profile on the baseline level"),
+			level:   podSecurityBaseline,
+			profile: SecurityProfileRootlessBuildah,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if caveat := podSecurityCaveat(tt.level, tt.profile); (caveat != "") != tt.wantCaveat {
+				t.Errorf("Caveat was %q, expected a caveat: %v", caveat, tt.wantCaveat)
+			}
+		})
+	}
+}
+
+func TestSecurityProfileLimitations(t *testing.T) {
+	tests := []struct {
+		name        string
+		profile     SecurityProfile
+		onOpenShift bool
+		want        int
+	}{
+		{
+			name:    fmt.Sprintf("Privileged profile"),
+			profile: SecurityProfilePrivileged,
+			want:    0,
+		},
+		{
+			name:    fmt.Sprintf("Restricted profile only sets seccomp through the annotation"),
+			profile: SecurityProfileRestricted,
+			want:    1,
+		},
+		{
+			name:    fmt.Sprintf("Rootless buildah profile outside of OpenShift may not get the fuse device"),
+			profile: SecurityProfileRootlessBuildah,
+			want:    2,
+		},
+		{
+			name:        fmt.Sprintf("Rootless buildah profile on OpenShift gets the fuse device from CRI-O"),
+			profile:     SecurityProfileRootlessBuildah,
+			onOpenShift: true,
+			want:        1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if limitations := securityProfileLimitations(tt.profile, tt.onOpenShift); len(limitations) != tt.want {
+				t.Errorf("Limitations were %q, expected %v", limitations, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSecurityProfiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		pfe         string
+		performance string
+		wantErr     bool
+	}{
+		{
+			name: fmt.Sprintf("Default security profiles"),
+		},
+		{
+			name:        fmt.Sprintf("Rootless buildah PFE with a restricted Performance dashboard"),
+			pfe:         "rootless-buildah",
+			performance: "restricted",
+		},
+		{
+			name:    fmt.Sprintf("Restricted PFE"),
+			pfe:     "restricted",
+			wantErr: true,
+		},
+		{
+			name:        fmt.Sprintf("Rootless buildah Performance dashboard"),
+			performance: "rootless-buildah",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.Default()
+			if tt.pfe != "" {
+				settings.PFE.SecurityProfile = tt.pfe
+			}
+			if tt.performance != "" {
+				settings.Performance.SecurityProfile = tt.performance
+			}
+			_, _, err := GetSecurityProfiles(settings)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetSecurityProfiles returned error %v, expected error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package codewind
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"deploy-pfe/pkg/constants"
+
+	log "github.com/sirupsen/logrus"
+
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// rwxProvisioners maps (part of) the name of a storage provisioner to the file system it provides, for the provisioners
+// known to support ReadWriteMany volumes, which the PFE volume needs
+var rwxProvisioners = []struct {
+	provisioner string
+	fileSystem  string
+}{
+	{"nfs", "NFS"},
+	{"cephfs", "CephFS"},
+	{"efs.csi.aws.com", "Amazon EFS"},
+	{"azure-file", "Azure File"},
+	{"file.csi.azure.com", "Azure File"},
+	{"ibmc-file", "IBM Cloud File"},
+	{"file.csi.ibm.io", "IBM Cloud File"},
+}
+
+// defaultStorageClassAnnotations mark the default storage class of the cluster
+var defaultStorageClassAnnotations = []string{
+	"storageclass.kubernetes.io/is-default-class",
+	"storageclass.beta.kubernetes.io/is-default-class",
+}
+
+// ResolveStorageClass determines the storage class of the PFE volume, logging why it was chosen:
+//  1. the storage class that was set explicitly (such as through $PFE_STORAGE_CLASS), which must exist
+//  2. the cluster's default storage class, if its provisioner is known to support ReadWriteMany volumes
+//  3. the ROKS file storage class defined in constants/default.go
+//  4. the first storage class (by name) whose provisioner is known to support ReadWriteMany volumes
+//
+// Storage classes are cluster scoped, so the service account may not be allowed to read them. An explicit storage class
+// is then used without checking that it exists, and otherwise no storage class is set, so that the PVC gets the
+// cluster's default storage class.
+//
+// An error listing the available storage classes is returned if none of them support ReadWriteMany volumes
+func ResolveStorageClass(clientset kubernetes.Interface, storageClass string) (string, error) {
+	if storageClass != "" {
+		_, err := clientset.StorageV1().StorageClasses().Get(storageClass, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return "", fmt.Errorf("storage class %s doesn't exist", storageClass)
+		} else if errors.IsForbidden(err) {
+			log.Warnf("Using storage class %s without checking that it exists, as storage classes can't be read: %v\n", storageClass, err)
+			return storageClass, nil
+		} else if err != nil {
+			return "", fmt.Errorf("unable to retrieve storage class %s: %v", storageClass, err)
+		}
+		log.Infof("Using storage class %s, as it was set explicitly\n", storageClass)
+		return storageClass, nil
+	}
+
+	storageClasses, err := clientset.StorageV1().StorageClasses().List(metav1.ListOptions{})
+	if errors.IsForbidden(err) {
+		log.Warnf("Using the cluster's default storage class, as storage classes can't be read to find one supporting ReadWriteMany volumes. "+
+			"Set $PFE_STORAGE_CLASS if the default storage class doesn't support ReadWriteMany: %v\n", err)
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("unable to list the storage classes to find one supporting ReadWriteMany volumes, set $PFE_STORAGE_CLASS instead: %v", err)
+	}
+	classes := storageClasses.Items
+	sort.Slice(classes, func(i, j int) bool { return classes[i].Name < classes[j].Name })
+
+	for _, class := range classes {
+		if fileSystem := rwxFileSystem(class); fileSystem != "" && isDefaultStorageClass(class) {
+			log.Infof("Using storage class %s, as it's the default storage class and provides %s volumes (%s)\n", class.Name, fileSystem, class.Provisioner)
+			return class.Name, nil
+		}
+	}
+	for _, class := range classes {
+		if class.Name == constants.ROKSStorageClass {
+			log.Infof("Using storage class %s, as it provides file storage on OpenShift on IBM Cloud\n", class.Name)
+			return class.Name, nil
+		}
+	}
+	for _, class := range classes {
+		if fileSystem := rwxFileSystem(class); fileSystem != "" {
+			log.Infof("Using storage class %s, as it provides %s volumes (%s), which support ReadWriteMany\n", class.Name, fileSystem, class.Provisioner)
+			return class.Name, nil
+		}
+	}
+
+	available := []string{}
+	for _, class := range classes {
+		available = append(available, class.Name+" ("+class.Provisioner+")")
+	}
+	if len(available) == 0 {
+		available = append(available, "none")
+	}
+	return "", fmt.Errorf("no storage class supporting ReadWriteMany volumes was found, which the Codewind volume needs. "+
+		"Install an NFS, CephFS, EFS, Azure File or IBM Cloud File provisioner, or set $PFE_STORAGE_CLASS to a storage class "+
+		"that supports ReadWriteMany. Available storage classes: %s", strings.Join(available, ", "))
+}
+
+// rwxFileSystem returns the file system provided by a storage class, if its provisioner is known to support
+// ReadWriteMany volumes
+func rwxFileSystem(class storagev1.StorageClass) string {
+	for _, known := range rwxProvisioners {
+		if strings.Contains(class.Provisioner, known.provisioner) {
+			return known.fileSystem
+		}
+	}
+	return ""
+}
+
+// isDefaultStorageClass returns true if the storage class is marked as the default storage class of the cluster
+func isDefaultStorageClass(class storagev1.StorageClass) bool {
+	for _, annotation := range defaultStorageClassAnnotations {
+		if class.Annotations[annotation] == "true" {
+			return true
+		}
+	}
+	return false
+}
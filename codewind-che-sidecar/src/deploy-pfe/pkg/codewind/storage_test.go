@@ -0,0 +1,125 @@
+package codewind
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newStorageClass returns a storage class with the given provisioner, optionally marked as the cluster default
+func newStorageClass(name string, provisioner string, isDefault bool) *storagev1.StorageClass {
+	class := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Provisioner: provisioner,
+	}
+	if isDefault {
+		class.Annotations = map[string]string{"storageclass.kubernetes.io/is-default-class": "true"}
+	}
+	return class
+}
+
+func TestResolveStorageClass(t *testing.T) {
+	tests := []struct {
+		name           string
+		storageClass   string
+		storageClasses []runtime.Object
+		want           string
+		wantErr        string
+	}{
+		{
+			name:           fmt.Sprintf("Explicit storage class"),
+			storageClass:   "gp2",
+			storageClasses: []runtime.Object{newStorageClass("gp2", "kubernetes.io/aws-ebs", true), newStorageClass("efs", "efs.csi.aws.com", false)},
+			want:           "gp2",
+		},
+		{
+			name:           fmt.Sprintf("Explicit storage class that doesn't exist"),
+			storageClass:   "missing",
+			storageClasses: []runtime.Object{newStorageClass("efs", "efs.csi.aws.com", false)},
+			wantErr:        "storage class missing doesn't exist",
+		},
+		{
+			name:           fmt.Sprintf("Default storage class supporting ReadWriteMany"),
+			storageClasses: []runtime.Object{newStorageClass("azurefile", "kubernetes.io/azure-file", false), newStorageClass("cephfs", "rook-ceph.cephfs.csi.ceph.com", true)},
+			want:           "cephfs",
+		},
+		{
+			name:           fmt.Sprintf("ROKS storage class"),
+			storageClasses: []runtime.Object{newStorageClass("ibmc-block-gold", "ibm.io/ibmc-block", true), newStorageClass("ibmc-file-silver", "ibm.io/ibmc-file", false), newStorageClass("ibmc-file-bronze", "ibm.io/ibmc-file", false)},
+			want:           "ibmc-file-bronze",
+		},
+		{
+			name:           fmt.Sprintf("ReadWriteMany storage class that isn't the default"),
+			storageClasses: []runtime.Object{newStorageClass("standard", "kubernetes.io/gce-pd", true), newStorageClass("nfs", "nfs.csi.k8s.io", false)},
+			want:           "nfs",
+		},
+		{
+			name:           fmt.Sprintf("No storage class supporting ReadWriteMany"),
+			storageClasses: []runtime.Object{newStorageClass("standard", "kubernetes.io/gce-pd", true)},
+			wantErr:        "standard (kubernetes.io/gce-pd)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.storageClasses...)
+			storageClass, err := ResolveStorageClass(clientset, tt.storageClass)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Error was %v, expected it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if storageClass != tt.want {
+				t.Errorf("Storage class was %s, expected %s", storageClass, tt.want)
+			}
+		})
+	}
+}
+
+// TestResolveStorageClassForbidden verifies that the PVC falls back to an unchecked explicit storage class, or the
+// cluster's default storage class, when the service account isn't allowed to read storage classes
+func TestResolveStorageClassForbidden(t *testing.T) {
+	tests := []struct {
+		name         string
+		storageClass string
+		want         string
+	}{
+		{
+			name:         fmt.Sprintf("Explicit storage class that can't be read"),
+			storageClass: "efs",
+			want:         "efs",
+		},
+		{
+			name: fmt.Sprintf("Storage classes that can't be listed"),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(newStorageClass("efs", "efs.csi.aws.com", false))
+			clientset.PrependReactor("*", "storageclasses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewForbidden(schema.GroupResource{Group: "storage.k8s.io", Resource: "storageclasses"}, "", fmt.Errorf("cluster scoped"))
+			})
+			storageClass, err := ResolveStorageClass(clientset, tt.storageClass)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if storageClass != tt.want {
+				t.Errorf("Storage class was %q, expected %q", storageClass, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package codewind
+
+import (
+	"deploy-pfe/pkg/kube"
+
+	log "github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	routev1 "github.com/openshift/client-go/route/clientset/versioned/typed/route/v1"
+)
+
+// TeardownCodewind deletes every Codewind resource labelled with the given workspace ID from the namespace.
+// Resources are removed in dependency order: the ingress, route or Gateway API route first, so that Codewind stops
+// being reachable, then the deployments and services, the anchor ConfigMap, and finally the PFE volume (unless keepPVC
+// is set).
+// routeClient may be nil when not running on OpenShift. The kind and name of every removed resource is returned,
+// including the ones removed before an error was hit.
+func TeardownCodewind(clientset kubernetes.Interface, dynamicClient dynamic.Interface, routeClient routev1.RouteV1Interface, namespace string, workspaceID string, keepPVC bool) ([]string, error) {
+	listOptions := metav1.ListOptions{
+		LabelSelector: "codewindWorkspace=" + workspaceID,
+	}
+	propagation := metav1.DeletePropagationBackground
+	deleteOptions := &metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	}
+
+	removed, err := teardownExposure(clientset, dynamicClient, routeClient, namespace, listOptions, deleteOptions, []string{})
+	if err != nil {
+		return removed, err
+	}
+
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(listOptions)
+	if err != nil {
+		return removed, err
+	}
+	for _, deploy := range deployments.Items {
+		err = clientset.AppsV1().Deployments(namespace).Delete(deploy.GetName(), deleteOptions)
+		if err != nil && !errors.IsNotFound(err) {
+			return removed, err
+		}
+		removed = teardownRemoved(removed, "Deployment", deploy.GetName())
+	}
+
+	services, err := clientset.CoreV1().Services(namespace).List(listOptions)
+	if err != nil {
+		return removed, err
+	}
+	for _, service := range services.Items {
+		err = clientset.CoreV1().Services(namespace).Delete(service.GetName(), deleteOptions)
+		if err != nil && !errors.IsNotFound(err) {
+			return removed, err
+		}
+		removed = teardownRemoved(removed, "Service", service.GetName())
+	}
+
+	// Deleting the anchor ConfigMap (when using the configmap ownership strategy) would garbage collect everything else
+	// as well, so it's only removed once the resources it owns are gone
+	configMaps, err := clientset.CoreV1().ConfigMaps(namespace).List(listOptions)
+	if err != nil {
+		return removed, err
+	}
+	for _, configMap := range configMaps.Items {
+		err = clientset.CoreV1().ConfigMaps(namespace).Delete(configMap.GetName(), deleteOptions)
+		if err != nil && !errors.IsNotFound(err) {
+			return removed, err
+		}
+		removed = teardownRemoved(removed, "ConfigMap", configMap.GetName())
+	}
+
+	if keepPVC {
+		log.Infoln("Keeping the Codewind persistent volume claim")
+		return removed, nil
+	}
+
+	pvcs, err := clientset.CoreV1().PersistentVolumeClaims(namespace).List(listOptions)
+	if err != nil {
+		return removed, err
+	}
+	for _, pvc := range pvcs.Items {
+		err = clientset.CoreV1().PersistentVolumeClaims(namespace).Delete(pvc.GetName(), deleteOptions)
+		if err != nil && !errors.IsNotFound(err) {
+			return removed, err
+		}
+		removed = teardownRemoved(removed, "PersistentVolumeClaim", pvc.GetName())
+	}
+
+	return removed, nil
+}
+
+// RemoveExposure deletes the route, ingress and Gateway API routes labelled with the given workspace ID from the
+// namespace, so that Codewind is no longer reachable from outside of the cluster. routeClient may be nil when not
+// running on OpenShift. The kind and name of every removed resource is returned
+func RemoveExposure(clientset kubernetes.Interface, dynamicClient dynamic.Interface, routeClient routev1.RouteV1Interface, namespace string, workspaceID string) ([]string, error) {
+	listOptions := metav1.ListOptions{
+		LabelSelector: "codewindWorkspace=" + workspaceID,
+	}
+	propagation := metav1.DeletePropagationBackground
+	return teardownExposure(clientset, dynamicClient, routeClient, namespace, listOptions, &metav1.DeleteOptions{PropagationPolicy: &propagation}, []string{})
+}
+
+// teardownExposure deletes the routes, ingresses and Gateway API routes matching the list options
+func teardownExposure(clientset kubernetes.Interface, dynamicClient dynamic.Interface, routeClient routev1.RouteV1Interface, namespace string, listOptions metav1.ListOptions, deleteOptions *metav1.DeleteOptions, removed []string) ([]string, error) {
+	if routeClient != nil {
+		routes, err := routeClient.Routes(namespace).List(listOptions)
+		if err != nil {
+			return removed, err
+		}
+		for _, route := range routes.Items {
+			err = routeClient.Routes(namespace).Delete(route.GetName(), deleteOptions)
+			if err != nil && !errors.IsNotFound(err) {
+				return removed, err
+			}
+			removed = teardownRemoved(removed, "Route", route.GetName())
+		}
+	}
+
+	// Ingresses are looked up through the newest ingress API version that the cluster serves
+	ingressAPIVersion, err := kube.DetectIngressAPIVersion(clientset.Discovery())
+	if err != nil {
+		log.Warnf("Skipping ingresses: %v\n", err)
+	} else {
+		removed, err = teardownUnstructured(dynamicClient.Resource(ingressResource(ingressAPIVersion)).Namespace(namespace), "Ingress", listOptions, deleteOptions, removed)
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	// Gateway API routes only exist on clusters with the Gateway API installed
+	for _, kind := range []string{GatewayTLSRoute, GatewayHTTPRoute} {
+		apiVersions, resource := GatewayRouteAPIVersions(kind)
+		apiVersion, err := kube.DetectAPIVersion(clientset.Discovery(), resource, apiVersions)
+		if err != nil {
+			continue
+		}
+		removed, err = teardownUnstructured(dynamicClient.Resource(gatewayRouteResource(apiVersion, kind)).Namespace(namespace), kind, listOptions, deleteOptions, removed)
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	return removed, nil
+}
+
+// teardownUnstructured deletes the objects of a resource that we don't build against (such as ingresses) matching
+// the list options, and adds them to the list of removed resources
+func teardownUnstructured(resources dynamic.ResourceInterface, kind string, listOptions metav1.ListOptions, deleteOptions *metav1.DeleteOptions, removed []string) ([]string, error) {
+	objects, err := resources.List(listOptions)
+	if err != nil {
+		return removed, err
+	}
+	for _, object := range objects.Items {
+		err = resources.Delete(object.GetName(), deleteOptions)
+		if err != nil && !errors.IsNotFound(err) {
+			return removed, err
+		}
+		removed = teardownRemoved(removed, kind, object.GetName())
+	}
+	return removed, nil
+}
+
+// teardownRemoved logs the removal of a resource and adds it to the list of removed resources
+func teardownRemoved(removed []string, kind string, name string) []string {
+	log.Infof("Deleted %s %s\n", kind, name)
+	return append(removed, kind+"/"+name)
+}
@@ -0,0 +1,138 @@
+package codewind
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	routefake "github.com/openshift/client-go/route/clientset/versioned/fake"
+	routev1 "github.com/openshift/client-go/route/clientset/versioned/typed/route/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTeardownCodewind(t *testing.T) {
+	tests := []struct {
+		name          string
+		onOpenShift   bool
+		exposure      ExposureStrategy
+		keepPVC       bool
+		noRouteClient bool
+		removed       []string
+	}{
+		{
+			name: fmt.Sprintf("Tear down Codewind with an ingress"),
+			removed: []string{
+				"Ingress/codewind-workspace1erok6723m74axkg",
+				"Deployment/codewind-workspace1erok6723m74axkg",
+				"Deployment/codewind-performance-workspace1erok6723m74axkg",
+				"Service/codewind-workspace1erok6723m74axkg",
+				"Service/codewind-performance-workspace1erok6723m74axkg",
+				"PersistentVolumeClaim/codewind-workspace1erok6723m74axkg",
+			},
+		},
+		{
+			name:     fmt.Sprintf("Tear down Codewind with a Gateway API route"),
+			exposure: ExposureGateway,
+			removed: []string{
+				"TLSRoute/codewind-workspace1erok6723m74axkg",
+				"Deployment/codewind-workspace1erok6723m74axkg",
+				"Deployment/codewind-performance-workspace1erok6723m74axkg",
+				"Service/codewind-workspace1erok6723m74axkg",
+				"Service/codewind-performance-workspace1erok6723m74axkg",
+				"PersistentVolumeClaim/codewind-workspace1erok6723m74axkg",
+			},
+		},
+		{
+			name:        fmt.Sprintf("Tear down Codewind with a route, keeping the PVC"),
+			onOpenShift: true,
+			keepPVC:     true,
+			removed: []string{
+				"Route/codewind-workspace1erok6723m74axkg",
+				"Deployment/codewind-workspace1erok6723m74axkg",
+				"Deployment/codewind-performance-workspace1erok6723m74axkg",
+				"Service/codewind-workspace1erok6723m74axkg",
+				"Service/codewind-performance-workspace1erok6723m74axkg",
+			},
+		},
+		{
+			name:          fmt.Sprintf("Tear down Codewind without a route client, keeping the PVC"),
+			keepPVC:       true,
+			noRouteClient: true,
+			removed: []string{
+				"Ingress/codewind-workspace1erok6723m74axkg",
+				"Deployment/codewind-workspace1erok6723m74axkg",
+				"Deployment/codewind-performance-workspace1erok6723m74axkg",
+				"Service/codewind-workspace1erok6723m74axkg",
+				"Service/codewind-performance-workspace1erok6723m74axkg",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codewindInstance := setupCodewind()
+			clientset := fake.NewSimpleClientset(setupWorkspacePVC(codewindInstance), setupStorageClass())
+			clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = append(ingressAPIResources("networking.k8s.io/v1"), &metav1.APIResourceList{
+				GroupVersion: "gateway.networking.k8s.io/v1alpha2",
+				APIResources: []metav1.APIResource{{Name: "tlsroutes", Kind: "TLSRoute", Namespaced: true}},
+			})
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			routeClient := routefake.NewSimpleClientset()
+
+			// Deploy Codewind first, so that there is something to tear down
+			err := DeployCodewind(clientset, codewindInstance, codewindInstance.Namespace, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.exposure == ExposureGateway {
+				codewindInstance.Gateway = GatewaySettings{Name: "codewind-gateway"}
+				err = ReconcileGatewayRoute(dynamicClient, CreateGatewayRoute(codewindInstance), codewindInstance.Namespace, nil)
+			} else if tt.onOpenShift {
+				err = ReconcileRoute(routeClient.RouteV1(), CreateRoute(codewindInstance), codewindInstance.Namespace, nil)
+			} else {
+				err = ReconcileIngress(dynamicClient, CreateIngress(codewindInstance), codewindInstance.Namespace, nil)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Routes only exist on OpenShift, where teardown is given a route client
+			var routeV1 routev1.RouteV1Interface
+			if !tt.noRouteClient {
+				routeV1 = routeClient.RouteV1()
+			}
+			removed, err := TeardownCodewind(clientset, dynamicClient, routeV1, codewindInstance.Namespace, codewindInstance.WorkspaceID, tt.keepPVC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Verify everything was removed, with the route or ingress first and the PVC last
+			if len(removed) != len(tt.removed) || removed[0] != tt.removed[0] || removed[len(removed)-1] != tt.removed[len(tt.removed)-1] {
+				t.Fatalf("Teardown removed %v, expected %v", removed, tt.removed)
+			}
+			sorted := append([]string{}, removed...)
+			sort.Strings(sorted)
+			sort.Strings(tt.removed)
+			if fmt.Sprint(sorted) != fmt.Sprint(tt.removed) {
+				t.Errorf("Teardown removed %v, expected %v", removed, tt.removed)
+			}
+
+			// The PFE volume is kept when requested
+			_, err = clientset.CoreV1().PersistentVolumeClaims(codewindInstance.Namespace).Get(codewindInstance.PVCName, metav1.GetOptions{})
+			if tt.keepPVC && err != nil {
+				t.Errorf("Teardown removed the PFE PVC, which was to be kept")
+			}
+			if !tt.keepPVC && err == nil {
+				t.Errorf("Teardown didn't remove the PFE PVC")
+			}
+
+			// The workspace PVC isn't labelled as a Codewind resource, so must never be removed
+			_, err = clientset.CoreV1().PersistentVolumeClaims(codewindInstance.Namespace).Get("claim-che-workspace", metav1.GetOptions{})
+			if err != nil {
+				t.Errorf("Teardown removed the workspace PVC")
+			}
+		})
+	}
+}
@@ -1,24 +1,61 @@
 package codewind
 
-import "k8s.io/apimachinery/pkg/types"
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
 
 // Codewind represents a Codewind instance: name, namespace, volume, serviceaccount, and pull secrets
 type Codewind struct {
-	PFEName            string
-	PerformanceName    string
-	PFEImage           string
-	PerformanceImage   string
-	Namespace          string
-	WorkspaceID        string
-	ServiceAccountName string
-	PullSecret         string
-	PVCName            string
-	OwnerReferenceName string
-	OwnerReferenceUID  types.UID
-	Privileged         bool
-	Ingress            string
-	OnOpenShift        bool
-	CheIngress         string
+	PFEName                    string
+	PerformanceName            string
+	PFEImage                   string
+	PerformanceImage           string
+	ImagePullPolicy            corev1.PullPolicy
+	Namespace                  string
+	WorkspaceID                string
+	ServiceAccountName         string
+	PullSecret                 string
+	PatchServiceAccount        bool
+	PVCName                    string
+	StorageClass               string
+	VolumeSize                 string
+	ShareWorkspaceVolume       bool
+	WorkspaceVolume            *WorkspaceVolume
+	PVCBindTimeout             time.Duration
+	OwnerReferenceName         string
+	OwnerReferenceUID          types.UID
+	OwnerReferenceKind         string
+	OwnerReferenceAPIVersion   string
+	PFESecurityProfile         SecurityProfile
+	PerformanceSecurityProfile SecurityProfile
+	Ingress                    string
+	OnOpenShift                bool
+	CheIngress                 string
+	IngressAPIVersion          string
+	IngressClass               string
+	IngressTLSSecret           string
+	IngressProfile             IngressProfile
+	IngressAnnotations         map[string]string
+	Route                      RouteSettings
+	Exposure                   ExposureStrategy
+	Gateway                    GatewaySettings
+	PFEProbe                   Probe
+	PerformanceProbe           Probe
+	PFEResources               corev1.ResourceRequirements
+	PerformanceResources       corev1.ResourceRequirements
+}
+
+// Probe represents the settings of the readiness and liveness probes of a Codewind container
+type Probe struct {
+	Path             string
+	ReadinessDelay   int32
+	LivenessDelay    int32
+	Period           int32
+	Timeout          int32
+	FailureThreshold int32
 }
 
 // ServiceAccountPatch contains an array of imagePullSecrets that will be patched into a Kubernetes service account
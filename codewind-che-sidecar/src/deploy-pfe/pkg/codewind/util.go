@@ -1,47 +1,20 @@
 package codewind
 
 import (
-	"encoding/json"
-	"os"
+	"fmt"
 	"strconv"
 
+	"deploy-pfe/pkg/config"
 	"deploy-pfe/pkg/constants"
 
-	"k8s.io/client-go/kubernetes"
-
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	extensionsv1 "k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	types "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
-
-	v1 "github.com/openshift/api/route/v1"
 )
 
-// PatchServiceAccount takes in a list of secret names, and patches it to the specified service account
-func PatchServiceAccount(clientset *kubernetes.Clientset, codewind Codewind) error {
-	patch := ServiceAccountPatch{
-		ImagePullSecrets: &[]ImagePullSecret{
-			{
-				Name: codewind.PullSecret,
-			},
-		},
-	}
-
-	b, err := json.Marshal(patch)
-	if err != nil {
-		return err
-	}
-
-	_, err = clientset.CoreV1().ServiceAccounts(codewind.Namespace).Patch(codewind.ServiceAccountName, types.StrategicMergePatchType, b)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 func setPFEEnvVars(codewind Codewind) []corev1.EnvVar {
 	return []corev1.EnvVar{
 		{
@@ -54,7 +27,7 @@ func setPFEEnvVars(codewind Codewind) []corev1.EnvVar {
 		},
 		{
 			Name:  "PORTAL_HTTPS",
-			Value: "true",
+			Value: strconv.FormatBool(pfeServesHTTPS(codewind)),
 		},
 		{
 			Name:  "KUBE_NAMESPACE",
@@ -70,7 +43,7 @@ func setPFEEnvVars(codewind Codewind) []corev1.EnvVar {
 		},
 		{
 			Name:  "PVC_NAME",
-			Value: codewind.PVCName,
+			Value: pfeClaimName(codewind),
 		},
 		{
 			Name:  "SERVICE_NAME",
@@ -100,6 +73,14 @@ func setPFEEnvVars(codewind Codewind) []corev1.EnvVar {
 			Name:  "OWNER_REF_UID",
 			Value: string(codewind.OwnerReferenceUID),
 		},
+		{
+			Name:  "OWNER_REF_KIND",
+			Value: codewind.OwnerReferenceKind,
+		},
+		{
+			Name:  "OWNER_REF_API_VERSION",
+			Value: codewind.OwnerReferenceAPIVersion,
+		},
 		{
 			Name:  "CODEWIND_PERFORMANCE_SERVICE",
 			Value: constants.PerformancePrefix + "-" + codewind.WorkspaceID,
@@ -136,16 +117,30 @@ func setPerformanceEnvVars(codewind Codewind) []corev1.EnvVar {
 	}
 }
 
-// setPFEVolumes returns the 3 volumes & corresponding volume mounts required by the PFE container:
-// project workspace, buildah volume, and the docker registry secret (the latter of which is optional)
+// pfeClaimName returns the name of the PVC mounted by PFE: the Che workspace PVC when it's shared, otherwise PFE's own
+func pfeClaimName(codewind Codewind) string {
+	if codewind.WorkspaceVolume != nil {
+		return codewind.WorkspaceVolume.ClaimName
+	}
+	return codewind.PVCName
+}
+
+// setPFEVolumes returns the volumes & corresponding volume mounts required by the PFE container: the project workspace
+// (PFE's own PVC, or the Che workspace PVC when it's shared) and the buildah volume
 func setPFEVolumes(codewind Codewind) ([]corev1.Volume, []corev1.VolumeMount) {
+	// Mount the Che workspace PVC itself when it's shared, so that PFE sees the projects exactly where the IDE does
+	claimName := pfeClaimName(codewind)
+	subPath := codewind.WorkspaceID + "/projects"
+	if codewind.WorkspaceVolume != nil {
+		subPath = codewind.WorkspaceVolume.SubPath
+	}
 
 	volumes := []corev1.Volume{
 		{
 			Name: "shared-workspace",
 			VolumeSource: corev1.VolumeSource{
 				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-					ClaimName: codewind.PVCName,
+					ClaimName: claimName,
 				},
 			},
 		},
@@ -158,7 +153,7 @@ func setPFEVolumes(codewind Codewind) ([]corev1.Volume, []corev1.VolumeMount) {
 		{
 			Name:      "shared-workspace",
 			MountPath: "/codewind-workspace",
-			SubPath:   codewind.WorkspaceID + "/projects",
+			SubPath:   subPath,
 		},
 		{
 			Name:      "buildah-volume",
@@ -169,11 +164,59 @@ func setPFEVolumes(codewind Codewind) ([]corev1.Volume, []corev1.VolumeMount) {
 	return volumes, volumeMounts
 }
 
+// setProbes adds a readiness and a liveness probe to the given container, both probing the given path and port.
+// Kubernetes will only route traffic to the container once it's ready, and restarts it if it stops responding
+func setProbes(container *corev1.Container, probe Probe, port int, scheme corev1.URIScheme) {
+	handler := corev1.Handler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path:   probe.Path,
+			Port:   intstr.FromInt(port),
+			Scheme: scheme,
+		},
+	}
+	container.ReadinessProbe = &corev1.Probe{
+		Handler:             handler,
+		InitialDelaySeconds: probe.ReadinessDelay,
+		PeriodSeconds:       probe.Period,
+		TimeoutSeconds:      probe.Timeout,
+		SuccessThreshold:    1,
+		FailureThreshold:    probe.FailureThreshold,
+	}
+	container.LivenessProbe = &corev1.Probe{
+		Handler:             handler,
+		InitialDelaySeconds: probe.LivenessDelay,
+		PeriodSeconds:       probe.Period,
+		TimeoutSeconds:      probe.Timeout,
+		SuccessThreshold:    1,
+		FailureThreshold:    probe.FailureThreshold,
+	}
+}
+
+// ownerReferences returns the owner references that tie a Codewind resource to the owner of the Che workspace,
+// so that it's garbage collected along with the workspace. No owner references are returned if the workspace
+// has no known owner, in which case the resource has to be removed with `deploy-pfe teardown`
+func ownerReferences(codewind Codewind) []metav1.OwnerReference {
+	if codewind.OwnerReferenceUID == "" {
+		return nil
+	}
+
+	blockOwnerDeletion := true
+	controller := true
+	return []metav1.OwnerReference{
+		{
+			APIVersion:         codewind.OwnerReferenceAPIVersion,
+			BlockOwnerDeletion: &blockOwnerDeletion,
+			Controller:         &controller,
+			Kind:               codewind.OwnerReferenceKind,
+			Name:               codewind.OwnerReferenceName,
+			UID:                codewind.OwnerReferenceUID,
+		},
+	}
+}
+
 // generateDeployment returns a Kubernetes deployment object with the given name for the given image.
 // Additionally, volume/volumemounts and env vars can be specified.
 func generateDeployment(codewind Codewind, name string, image string, port int, volumes []corev1.Volume, volumeMounts []corev1.VolumeMount, envVars []corev1.EnvVar, labels map[string]string) appsv1.Deployment {
-	blockOwnerDeletion := true
-	controller := true
 	replicas := int32(1)
 	deployment := appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{
@@ -181,19 +224,10 @@ func generateDeployment(codewind Codewind, name string, image string, port int,
 			APIVersion: "apps/v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name + "-" + codewind.WorkspaceID,
-			Namespace: codewind.Namespace,
-			Labels:    labels,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion:         "apps/v1",
-					BlockOwnerDeletion: &blockOwnerDeletion,
-					Controller:         &controller,
-					Kind:               "ReplicaSet",
-					Name:               codewind.OwnerReferenceName,
-					UID:                codewind.OwnerReferenceUID,
-				},
-			},
+			Name:            name + "-" + codewind.WorkspaceID,
+			Namespace:       codewind.Namespace,
+			Labels:          labels,
+			OwnerReferences: ownerReferences(codewind),
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &replicas,
@@ -206,17 +240,15 @@ func generateDeployment(codewind Codewind, name string, image string, port int,
 				},
 				Spec: corev1.PodSpec{
 					ServiceAccountName: codewind.ServiceAccountName,
+					ImagePullSecrets:   imagePullSecrets(codewind),
 					Volumes:            volumes,
 					Containers: []corev1.Container{
 						{
 							Name:            name,
 							Image:           image,
-							ImagePullPolicy: constants.ImagePullPolicy,
-							SecurityContext: &corev1.SecurityContext{
-								Privileged: &codewind.Privileged,
-							},
-							VolumeMounts: volumeMounts,
-							Env:          envVars,
+							ImagePullPolicy: imagePullPolicyOf(codewind),
+							VolumeMounts:    volumeMounts,
+							Env:             envVars,
 							Ports: []corev1.ContainerPort{
 								{
 									ContainerPort: int32(port),
@@ -234,8 +266,6 @@ func generateDeployment(codewind Codewind, name string, image string, port int,
 // generateService returns a Kubernetes service object with the given name, exposed over the specified port
 // for the container with the given labels.
 func generateService(codewind Codewind, name string, port int, labels map[string]string) corev1.Service {
-	blockOwnerDeletion := true
-	controller := true
 
 	service := corev1.Service{
 		TypeMeta: metav1.TypeMeta{
@@ -243,148 +273,34 @@ func generateService(codewind Codewind, name string, port int, labels map[string
 			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name + "-" + codewind.WorkspaceID,
-			Namespace: codewind.Namespace,
-			Labels:    labels,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion:         "apps/v1",
-					BlockOwnerDeletion: &blockOwnerDeletion,
-					Controller:         &controller,
-					Kind:               "ReplicaSet",
-					Name:               codewind.OwnerReferenceName,
-					UID:                codewind.OwnerReferenceUID,
-				},
-			},
+			Name:            name + "-" + codewind.WorkspaceID,
+			Namespace:       codewind.Namespace,
+			Labels:          labels,
+			OwnerReferences: ownerReferences(codewind),
 		},
 		Spec: corev1.ServiceSpec{
 			Ports: []corev1.ServicePort{
 				{
-					Port: int32(port),
-					Name: name + "-http",
+					Port:       int32(port),
+					TargetPort: intstr.FromInt(port),
+					Name:       name + "-http",
 				},
 			},
 			Selector: labels,
+			Type:     corev1.ServiceTypeClusterIP,
 		},
 	}
 	return service
 }
 
-// CreateRoute returns an OpenShift route for the Codewind PFE service
-func CreateRoute(codewind Codewind) v1.Route {
-	labels := map[string]string{
-		"app":               constants.PFEPrefix,
-		"codewindWorkspace": codewind.WorkspaceID,
-	}
-
-	weight := int32(100)
-	blockOwnerDeletion := true
-	controller := true
-
-	return v1.Route{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "Route",
-			APIVersion: "route.openshift.io/v1",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   constants.PFEPrefix + "-" + codewind.WorkspaceID,
-			Labels: labels,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion:         "apps/v1",
-					BlockOwnerDeletion: &blockOwnerDeletion,
-					Controller:         &controller,
-					Kind:               "ReplicaSet",
-					Name:               codewind.OwnerReferenceName,
-					UID:                codewind.OwnerReferenceUID,
-				},
-			},
-		},
-		Spec: v1.RouteSpec{
-			Host: codewind.Ingress,
-			Port: &v1.RoutePort{
-				TargetPort: intstr.FromInt(constants.PFEContainerPort),
-			},
-			TLS: &v1.TLSConfig{
-				InsecureEdgeTerminationPolicy: v1.InsecureEdgeTerminationPolicyRedirect,
-				Termination:                   v1.TLSTerminationPassthrough,
-			},
-			To: v1.RouteTargetReference{
-				Kind:   "Service",
-				Name:   constants.PFEPrefix + "-" + codewind.WorkspaceID,
-				Weight: &weight,
-			},
-		},
-	}
-}
-
-// CreateIngress returns a Kubernetes ingress for the Codewind PFE service
-func CreateIngress(codewind Codewind) extensionsv1.Ingress {
-	labels := map[string]string{
-		"app":               constants.PFEPrefix,
-		"codewindWorkspace": codewind.WorkspaceID,
-	}
-
-	annotations := map[string]string{
-		"nginx.ingress.kubernetes.io/rewrite-target":   "/",
-		"nginx.ingress.kubernetes.io/backend-protocol": "HTTPS",
-	}
-	blockOwnerDeletion := true
-	controller := true
-
-	return extensionsv1.Ingress{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "extensions/v1beta1",
-			Kind:       "Ingress",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        constants.PFEPrefix + "-" + codewind.WorkspaceID,
-			Annotations: annotations,
-			Labels:      labels,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion:         "apps/v1",
-					BlockOwnerDeletion: &blockOwnerDeletion,
-					Controller:         &controller,
-					Kind:               "ReplicaSet",
-					Name:               codewind.OwnerReferenceName,
-					UID:                codewind.OwnerReferenceUID,
-				},
-			},
-		},
-		Spec: extensionsv1.IngressSpec{
-			Rules: []extensionsv1.IngressRule{
-				{
-					Host: codewind.Ingress,
-					IngressRuleValue: extensionsv1.IngressRuleValue{
-						HTTP: &extensionsv1.HTTPIngressRuleValue{
-							Paths: []extensionsv1.HTTPIngressPath{
-								{
-									Path: "/",
-									Backend: extensionsv1.IngressBackend{
-										ServiceName: constants.PFEPrefix + "-" + codewind.WorkspaceID,
-										ServicePort: intstr.FromInt(constants.PFEContainerPort),
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-}
-
-// GeneratePVC creates a persistent volume claim for PFE
+// GeneratePVC creates a persistent volume claim for PFE, owned by the Che workspace PVC. No owner reference is set if
+// the UID of the workspace PVC isn't known (such as when rendering), as the API server rejects owner references without one
 func generatePVC(codewind Codewind, volumeSize string, storageClass string, wsPVCName string, wsPVCUID types.UID) corev1.PersistentVolumeClaim {
 	labels := map[string]string{
 		"app":               constants.PFEPrefix,
 		"codewindWorkspace": codewind.WorkspaceID,
 	}
 
-	blockOwnerDeletion := true
-	controller := false
-
 	pvc := corev1.PersistentVolumeClaim{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
@@ -393,16 +309,6 @@ func generatePVC(codewind Codewind, volumeSize string, storageClass string, wsPV
 		ObjectMeta: metav1.ObjectMeta{
 			Name:   codewind.PVCName,
 			Labels: labels,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion:         "v1",
-					BlockOwnerDeletion: &blockOwnerDeletion,
-					Controller:         &controller,
-					Kind:               "PersistentVolumeClaim",
-					Name:               wsPVCName,
-					UID:                wsPVCUID,
-				},
-			},
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
 			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
@@ -414,6 +320,21 @@ func generatePVC(codewind Codewind, volumeSize string, storageClass string, wsPV
 		},
 	}
 
+	if wsPVCUID != "" {
+		blockOwnerDeletion := true
+		controller := false
+		pvc.OwnerReferences = []metav1.OwnerReference{
+			{
+				APIVersion:         "v1",
+				BlockOwnerDeletion: &blockOwnerDeletion,
+				Controller:         &controller,
+				Kind:               "PersistentVolumeClaim",
+				Name:               wsPVCName,
+				UID:                wsPVCUID,
+			},
+		}
+	}
+
 	// If a storage class was passed in, set it in the PVC
 
 	if storageClass != "" {
@@ -422,24 +343,125 @@ func generatePVC(codewind Codewind, volumeSize string, storageClass string, wsPV
 	return pvc
 }
 
-// GetImages returns the images that are to be used for PFE and the Performance dashboard in Codewind
-// If environment vars are set (such as $PFE_IMAGE, $PFE_TAG, $PERFORMANCE_IMAGE, or $PERFORMANCE_TAG), it will use those,
-// otherwise it defaults to the constants defined in constants/default.go
-func GetImages() (string, string) {
-	var pfeImage, performanceImage, pfeTag, performanceTag string
+// GetImages returns the images that are to be used for PFE and the Performance dashboard in Codewind, from the image
+// and tag settings of each container
+func GetImages(settings *config.Config) (string, string) {
+	return settings.PFE.Image + ":" + settings.PFE.Tag, settings.Performance.Image + ":" + settings.Performance.Tag
+}
+
+// GetImagePullPolicy returns the pull policy of the Codewind containers (Always, IfNotPresent or Never), defaulting to
+// the pull policy defined in constants/default.go if it's empty
+func GetImagePullPolicy(settings *config.Config) (corev1.PullPolicy, error) {
+	policy := corev1.PullPolicy(settings.ImagePullPolicy)
+	switch policy {
+	case "":
+		return constants.ImagePullPolicy, nil
+	case corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid value %q for imagePullPolicy, expected %s, %s or %s", policy, corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever)
+}
+
+// imagePullPolicyOf returns the pull policy of the containers of a Codewind instance, defaulting it if it wasn't set
+func imagePullPolicyOf(codewind Codewind) corev1.PullPolicy {
+	if codewind.ImagePullPolicy == "" {
+		return constants.ImagePullPolicy
+	}
+	return codewind.ImagePullPolicy
+}
+
+// GetProbes returns the probe settings that are to be used for PFE and the Performance dashboard in Codewind, checking
+// that the delays aren't negative and the period, timeout and failure threshold are at least 1
+func GetProbes(settings *config.Config) (Probe, Probe, error) {
+	pfeProbe, err := getProbe("pfe", settings.PFE.Probe)
+	if err != nil {
+		return Probe{}, Probe{}, err
+	}
+
+	performanceProbe, err := getProbe("performance", settings.Performance.Probe)
+	if err != nil {
+		return Probe{}, Probe{}, err
+	}
+
+	return pfeProbe, performanceProbe, nil
+}
+
+// getProbe checks the probe settings of the container with the given config key
+func getProbe(key string, probe config.ProbeConfig) (Probe, error) {
+	settings := []struct {
+		key   string
+		value int32
+		min   int32
+	}{
+		{key: key + ".probe.readinessDelay", value: probe.ReadinessDelay, min: 0},
+		{key: key + ".probe.livenessDelay", value: probe.LivenessDelay, min: 0},
+		{key: key + ".probe.period", value: probe.Period, min: 1},
+		{key: key + ".probe.timeout", value: probe.Timeout, min: 1},
+		{key: key + ".probe.failureThreshold", value: probe.FailureThreshold, min: 1},
+	}
+	for _, setting := range settings {
+		if setting.value < setting.min {
+			return Probe{}, fmt.Errorf("invalid value %d for %s, expected a whole number of at least %d", setting.value, setting.key, setting.min)
+		}
+	}
+	return Probe{
+		Path:             probe.Path,
+		ReadinessDelay:   probe.ReadinessDelay,
+		LivenessDelay:    probe.LivenessDelay,
+		Period:           probe.Period,
+		Timeout:          probe.Timeout,
+		FailureThreshold: probe.FailureThreshold,
+	}, nil
+}
 
-	if pfeImage = os.Getenv("PFE_IMAGE"); pfeImage == "" {
-		pfeImage = constants.PFEImage
+// GetResources returns the CPU and memory requests and limits that are to be used for PFE and the Performance dashboard
+// in Codewind. An error is returned if a value isn't a valid quantity, or a request exceeds its limit
+func GetResources(settings *config.Config) (corev1.ResourceRequirements, corev1.ResourceRequirements, error) {
+	pfeResources, err := getResources("pfe", settings.PFE.Resources)
+	if err != nil {
+		return corev1.ResourceRequirements{}, corev1.ResourceRequirements{}, err
 	}
-	if performanceImage = os.Getenv("PERFORMANCE_IMAGE"); performanceImage == "" {
-		performanceImage = constants.PerformanceImage
+
+	performanceResources, err := getResources("performance", settings.Performance.Resources)
+	if err != nil {
+		return corev1.ResourceRequirements{}, corev1.ResourceRequirements{}, err
+	}
+
+	return pfeResources, performanceResources, nil
+}
+
+// getResources parses the requests and limits of the container with the given config key
+func getResources(key string, settings config.ResourcesConfig) (corev1.ResourceRequirements, error) {
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{},
+		Limits:   corev1.ResourceList{},
 	}
-	if pfeTag = os.Getenv("PFE_TAG"); pfeTag == "" {
-		pfeTag = constants.PFEImageTag
+
+	quantities := []struct {
+		key      string
+		value    string
+		list     corev1.ResourceList
+		resource corev1.ResourceName
+	}{
+		{key: key + ".resources.cpuRequest", value: settings.CPURequest, list: resources.Requests, resource: corev1.ResourceCPU},
+		{key: key + ".resources.memoryRequest", value: settings.MemoryRequest, list: resources.Requests, resource: corev1.ResourceMemory},
+		{key: key + ".resources.cpuLimit", value: settings.CPULimit, list: resources.Limits, resource: corev1.ResourceCPU},
+		{key: key + ".resources.memoryLimit", value: settings.MemoryLimit, list: resources.Limits, resource: corev1.ResourceMemory},
 	}
-	if performanceTag = os.Getenv("PERFORMANCE_TAG"); performanceTag == "" {
-		performanceTag = constants.PerformanceTag
+	for _, setting := range quantities {
+		quantity, err := resource.ParseQuantity(setting.value)
+		if err != nil || quantity.Sign() <= 0 {
+			return corev1.ResourceRequirements{}, fmt.Errorf("invalid value %q for %s, expected a positive quantity such as 500m or 1Gi", setting.value, setting.key)
+		}
+		setting.list[setting.resource] = quantity
 	}
 
-	return pfeImage + ":" + pfeTag, performanceImage + ":" + performanceTag
+	for name, request := range resources.Requests {
+		limit := resources.Limits[name]
+		if request.Cmp(limit) > 0 {
+			setting := key + ".resources." + string(name)
+			return corev1.ResourceRequirements{}, fmt.Errorf("%s request of %s exceeds its limit of %s, check %sRequest and %sLimit", name, request.String(), limit.String(), setting, setting)
+		}
+	}
+	return resources, nil
 }
@@ -0,0 +1,202 @@
+package codewind
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"deploy-pfe/pkg/che"
+	"deploy-pfe/pkg/config"
+	"deploy-pfe/pkg/constants"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// pvcPollInterval is how often the PFE PVC is checked while waiting for it to be bound
+const pvcPollInterval = 2 * time.Second
+
+// GetPVCBindTimeout returns how long to wait for the PFE PVC to be bound, which can't be negative. A timeout of 0
+// disables waiting
+func GetPVCBindTimeout(settings *config.Config) (time.Duration, error) {
+	timeout := settings.Volume.BindTimeout
+	if timeout < 0 {
+		return 0, fmt.Errorf("invalid value %v for volume.bindTimeout, expected a duration such as 90s or 5m", timeout)
+	}
+	return timeout, nil
+}
+
+// WorkspaceVolume is the Che workspace PVC that PFE mounts directly, instead of a PVC of its own, and the subpath of
+// the workspace projects on it
+type WorkspaceVolume struct {
+	ClaimName string
+	SubPath   string
+}
+
+// ResolveWorkspaceVolume finds the Che workspace PVC and the subpath of the projects on it, from the /projects volume
+// mount of the workspace pod, so that PFE sees the same files as the IDE. nil is returned (and a warning logged) if the
+// workspace PVC doesn't support ReadWriteMany, as PFE then needs a PVC of its own
+func ResolveWorkspaceVolume(clientset kubernetes.Interface, namespace string, workspaceID string) (*WorkspaceVolume, error) {
+	pvc, subPath, err := che.GetWorkspaceProjectsVolume(clientset, namespace, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, accessMode := range pvc.Spec.AccessModes {
+		if accessMode == corev1.ReadWriteMany {
+			log.Infof("Sharing Che workspace persistent volume claim %s with Codewind, at subpath %s\n", pvc.Name, subPath)
+			return &WorkspaceVolume{ClaimName: pvc.Name, SubPath: subPath}, nil
+		}
+	}
+	log.Warnf("Che workspace persistent volume claim %s doesn't support ReadWriteMany and can't be shared with Codewind, creating a separate volume instead\n", pvc.Name)
+	return nil, nil
+}
+
+// volumeSizeOf returns the size of the PFE volume of a Codewind instance, defaulting it if it wasn't set
+func volumeSizeOf(codewind Codewind) string {
+	if codewind.VolumeSize == "" {
+		return constants.PFEVolumeSize
+	}
+	return codewind.VolumeSize
+}
+
+// ExpandPVC grows the storage request of an existing PVC to the given size, if it's larger than the current request.
+// Whether the storage class of the PVC allows volume expansion is left to the API server, which rejects the update
+// otherwise, as storage classes are cluster scoped. A warning is logged if the PVC can't be expanded, as PFE still
+// runs on the smaller volume. PVCs are never shrunk, as Kubernetes doesn't support it
+func ExpandPVC(clientset kubernetes.Interface, pvc *corev1.PersistentVolumeClaim, volumeSize string) error {
+	size, err := resource.ParseQuantity(volumeSize)
+	if err != nil {
+		return fmt.Errorf("invalid volume size %q: %v", volumeSize, err)
+	}
+	current := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	switch size.Cmp(current) {
+	case 0:
+		return nil
+	case -1:
+		log.Infof("Persistent volume claim %s requests %s, which is kept as volumes can't be shrunk to %s\n", pvc.Name, current.String(), volumeSize)
+		return nil
+	}
+
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		log.Warnf("Persistent volume claim %s can't be expanded from %s to %s, as it has no storage class that allows volume expansion\n", pvc.Name, current.String(), volumeSize)
+		return nil
+	}
+	expanded := pvc.DeepCopy()
+	if expanded.Spec.Resources.Requests == nil {
+		expanded.Spec.Resources.Requests = corev1.ResourceList{}
+	}
+	expanded.Spec.Resources.Requests[corev1.ResourceStorage] = size
+	_, err = clientset.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(expanded)
+	if errors.IsForbidden(err) || errors.IsInvalid(err) {
+		log.Warnf("Persistent volume claim %s can't be expanded from %s to %s, as storage class %s may not allow volume expansion: %v\n", pvc.Name, current.String(), volumeSize, *pvc.Spec.StorageClassName, err)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to expand persistent volume claim %s to %s: %v", pvc.Name, volumeSize, err)
+	}
+	log.Infof("Expanded persistent volume claim %s from %s to %s\n", pvc.Name, current.String(), volumeSize)
+	return nil
+}
+
+// WaitForPVCBound waits until the given PVC is bound to a volume. A *PVCNotBoundError holding the warning events of the
+// PVC (such as ProvisioningFailed) is returned if it isn't bound before the timeout. PVCs of storage classes that only
+// bind once a pod uses them (WaitForFirstConsumer) aren't waited for, as PFE is that pod. They're told apart by the
+// event reported for the PVC rather than by the storage class, which is cluster scoped
+func WaitForPVCBound(clientset kubernetes.Interface, namespace string, name string, timeout time.Duration) error {
+	log.Infof("Waiting up to %v for persistent volume claim %s to be bound...\n", timeout, name)
+	var phase corev1.PersistentVolumeClaimPhase
+	firstConsumer := false
+	err := wait.PollImmediate(pvcPollInterval, timeout, func() (bool, error) {
+		pvc, err := clientset.CoreV1().PersistentVolumeClaims(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		phase = pvc.Status.Phase
+		if phase == corev1.ClaimLost {
+			return false, &PVCNotBoundError{Name: name, Phase: string(phase), Timeout: timeout, Events: pvcEvents(clientset, namespace, name)}
+		}
+		if phase == corev1.ClaimBound {
+			return true, nil
+		}
+		firstConsumer = pvcWaitsForFirstConsumer(clientset, namespace, name)
+		return firstConsumer, nil
+	})
+
+	if err == wait.ErrWaitTimeout {
+		if phase == "" {
+			phase = corev1.ClaimPending
+		}
+		return &PVCNotBoundError{Name: name, Phase: string(phase), Timeout: timeout, Events: pvcEvents(clientset, namespace, name)}
+	} else if err != nil {
+		return err
+	}
+	if firstConsumer {
+		log.Infof("Persistent volume claim %s will be bound once Codewind is scheduled, as its storage class binds volumes on first use\n", name)
+		return nil
+	}
+	log.Infof("Persistent volume claim %s is bound\n", name)
+	return nil
+}
+
+// pvcWaitsForFirstConsumer returns whether the volume controller reported that the given PVC won't be bound until a
+// pod uses it, as its storage class has the WaitForFirstConsumer volume binding mode
+func pvcWaitsForFirstConsumer(clientset kubernetes.Interface, namespace string, name string) bool {
+	events, err := listPVCEvents(clientset, namespace, name)
+	if err != nil {
+		return false
+	}
+	for _, event := range events {
+		if event.Reason == string(storagev1.VolumeBindingWaitForFirstConsumer) {
+			return true
+		}
+	}
+	return false
+}
+
+// pvcEvents returns the warning events reported for the given PVC, oldest first
+func pvcEvents(clientset kubernetes.Interface, namespace string, name string) []string {
+	events, err := listPVCEvents(clientset, namespace, name)
+	if err != nil {
+		log.Warnf("Unable to retrieve the events of persistent volume claim %s: %v\n", name, err)
+		return nil
+	}
+
+	warnings := []corev1.Event{}
+	for _, event := range events {
+		if event.Type == corev1.EventTypeWarning {
+			warnings = append(warnings, event)
+		}
+	}
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].LastTimestamp.Before(&warnings[j].LastTimestamp) })
+
+	messages := []string{}
+	for _, event := range warnings {
+		messages = append(messages, fmt.Sprintf("%s: %s", event.Reason, event.Message))
+	}
+	return messages
+}
+
+// listPVCEvents returns the events reported for the given PVC
+func listPVCEvents(clientset kubernetes.Interface, namespace string, name string) ([]corev1.Event, error) {
+	events, err := clientset.CoreV1().Events(namespace).List(metav1.ListOptions{
+		FieldSelector: "involvedObject.kind=PersistentVolumeClaim,involvedObject.name=" + name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The field selector isn't applied by every client (such as fake ones), so the events are filtered again
+	pvcEvents := []corev1.Event{}
+	for _, event := range events.Items {
+		if event.InvolvedObject.Kind == "PersistentVolumeClaim" && event.InvolvedObject.Name == name {
+			pvcEvents = append(pvcEvents, event)
+		}
+	}
+	return pvcEvents, nil
+}
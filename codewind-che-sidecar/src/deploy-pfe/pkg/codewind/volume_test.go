@@ -0,0 +1,327 @@
+package codewind
+
+import (
+	"deploy-pfe/pkg/config"
+	"deploy-pfe/pkg/constants"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newPVCEvent returns an event reported for the PVC with the given name
+func newPVCEvent(name string, pvcName string, eventType string, reason string, message string) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: "PersistentVolumeClaim",
+			Name: pvcName,
+		},
+		Type:    eventType,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+func TestWaitForPVCBound(t *testing.T) {
+	storageClass := "nfs-client"
+	pvc := func(phase corev1.PersistentVolumeClaimPhase) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "codewind-pvc",
+				Namespace: "default",
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				StorageClassName: &storageClass,
+			},
+			Status: corev1.PersistentVolumeClaimStatus{
+				Phase: phase,
+			},
+		}
+	}
+	waitForFirstConsumer := storagev1.VolumeBindingWaitForFirstConsumer
+	firstConsumerClass := newStorageClass(storageClass, "cluster.local/nfs-subdir-external-provisioner", false)
+	firstConsumerClass.VolumeBindingMode = &waitForFirstConsumer
+	firstConsumerEvent := newPVCEvent("first-consumer", "codewind-pvc", corev1.EventTypeNormal, "WaitForFirstConsumer", "waiting for first consumer to be created before binding")
+
+	tests := []struct {
+		name                 string
+		objects              []runtime.Object
+		forbidStorageClasses bool
+		wantErr              bool
+		wantEvents           []string
+	}{
+		{
+			name:    fmt.Sprintf("Bound PVC"),
+			objects: []runtime.Object{pvc(corev1.ClaimBound), setupStorageClass()},
+		},
+		{
+			name:    fmt.Sprintf("Pending PVC of a storage class binding volumes on first use"),
+			objects: []runtime.Object{pvc(corev1.ClaimPending), firstConsumerClass, firstConsumerEvent},
+		},
+		{
+			name:                 fmt.Sprintf("Pending PVC binding on first use, without access to storage classes"),
+			objects:              []runtime.Object{pvc(corev1.ClaimPending), firstConsumerClass, firstConsumerEvent},
+			forbidStorageClasses: true,
+		},
+		{
+			name: fmt.Sprintf("Pending PVC reports its warning events"),
+			objects: []runtime.Object{
+				pvc(corev1.ClaimPending),
+				setupStorageClass(),
+				newPVCEvent("provisioning", "codewind-pvc", corev1.EventTypeWarning, "ProvisioningFailed", "exceeded quota"),
+				newPVCEvent("external", "codewind-pvc", corev1.EventTypeNormal, "ExternalProvisioning", "waiting for a volume to be created"),
+				newPVCEvent("other", "other-pvc", corev1.EventTypeWarning, "ProvisioningFailed", "not this PVC"),
+			},
+			wantErr:    true,
+			wantEvents: []string{"ProvisioningFailed: exceeded quota"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.objects...)
+			if tt.forbidStorageClasses {
+				// Storage classes are cluster scoped, so aren't readable by the workspace service account
+				clientset.PrependReactor("get", "storageclasses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.NewForbidden(storagev1.Resource("storageclasses"), action.(k8stesting.GetAction).GetName(), fmt.Errorf("cluster scoped"))
+				})
+			}
+			err := WaitForPVCBound(clientset, "default", "codewind-pvc", 10*time.Millisecond)
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("WaitForPVCBound returned error %v", err)
+				}
+				return
+			}
+			pvcErr, ok := err.(*PVCNotBoundError)
+			if !ok {
+				t.Fatalf("WaitForPVCBound returned %v, expected a *PVCNotBoundError", err)
+			}
+			if pvcErr.Phase != string(corev1.ClaimPending) {
+				t.Errorf("PVCNotBoundError has phase %s, expected %s", pvcErr.Phase, corev1.ClaimPending)
+			}
+			if fmt.Sprint(pvcErr.Events) != fmt.Sprint(tt.wantEvents) {
+				t.Errorf("PVCNotBoundError has events %v, expected %v", pvcErr.Events, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestExpandPVC(t *testing.T) {
+	pvc := func(storageClass string, size string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "codewind-pvc",
+				Namespace: "default",
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				StorageClassName: &storageClass,
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse(size),
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		pvc        *corev1.PersistentVolumeClaim
+		volumeSize string
+		want       string
+	}{
+		{
+			name:       fmt.Sprintf("Larger size expands a PVC of a storage class allowing expansion"),
+			pvc:        pvc("expandable", "5Gi"),
+			volumeSize: "10Gi",
+			want:       "10Gi",
+		},
+		{
+			name:       fmt.Sprintf("Larger size doesn't expand a PVC of a storage class not allowing expansion"),
+			pvc:        pvc("fixed", "5Gi"),
+			volumeSize: "10Gi",
+			want:       "5Gi",
+		},
+		{
+			name:       fmt.Sprintf("Smaller size doesn't shrink a PVC"),
+			pvc:        pvc("expandable", "10Gi"),
+			volumeSize: "5Gi",
+			want:       "10Gi",
+		},
+		{
+			name:       fmt.Sprintf("Same size in other units leaves a PVC as is"),
+			pvc:        pvc("expandable", "1Gi"),
+			volumeSize: "1024Mi",
+			want:       "1Gi",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.pvc)
+
+			// Storage classes are cluster scoped, so aren't readable by the workspace service account. Whether they allow
+			// volume expansion is checked by the API server instead, which rejects expanding PVCs of other classes
+			clientset.PrependReactor("get", "storageclasses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewForbidden(storagev1.Resource("storageclasses"), action.(k8stesting.GetAction).GetName(), fmt.Errorf("cluster scoped"))
+			})
+			clientset.PrependReactor("update", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				pvc := action.(k8stesting.UpdateAction).GetObject().(*corev1.PersistentVolumeClaim)
+				if *pvc.Spec.StorageClassName != "expandable" {
+					return true, nil, errors.NewForbidden(corev1.Resource("persistentvolumeclaims"), pvc.Name, fmt.Errorf("only dynamically provisioned pvc can be resized and the storageclass that provisions the pvc must support resize"))
+				}
+				return false, nil, nil
+			})
+
+			err := ExpandPVC(clientset, tt.pvc, tt.volumeSize)
+			if err != nil {
+				t.Fatalf("ExpandPVC returned error %v", err)
+			}
+			pvc, err := clientset.CoreV1().PersistentVolumeClaims("default").Get("codewind-pvc", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Unable to retrieve the PVC: %v", err)
+			}
+			size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+			if want := resource.MustParse(tt.want); size.Cmp(want) != 0 {
+				t.Errorf("PVC requests %s, expected %s", size.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPVCBindTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		wantErr bool
+	}{
+		{
+			name:    fmt.Sprintf("Bind timeout"),
+			timeout: 5 * time.Minute,
+		},
+		{
+			name:    fmt.Sprintf("Not waiting for the volume to be bound"),
+			timeout: 0,
+		},
+		{
+			name:    fmt.Sprintf("Negative bind timeout"),
+			timeout: -time.Minute,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.Default()
+			settings.Volume.BindTimeout = tt.timeout
+			timeout, err := GetPVCBindTimeout(settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetPVCBindTimeout returned error %v, expected error: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && timeout != tt.timeout {
+				t.Errorf("GetPVCBindTimeout returned %v, expected %v", timeout, tt.timeout)
+			}
+		})
+	}
+}
+
+func TestResolveWorkspaceVolume(t *testing.T) {
+	codewindInstance := setupCodewind()
+	workspacePod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      codewindInstance.WorkspaceID + ".ws-7d8b6c8f5-x2x7h",
+			Namespace: "default",
+			Labels:    map[string]string{"che.workspace_id": codewindInstance.WorkspaceID},
+		},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{
+					Name: "claim-che-workspace",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "claim-che-workspace"},
+					},
+				},
+			},
+			Containers: []corev1.Container{
+				{
+					Name:         "codewind-sidecar",
+					VolumeMounts: []corev1.VolumeMount{{Name: "claim-che-workspace", MountPath: "/projects", SubPath: codewindInstance.WorkspaceID + "/projects"}},
+				},
+			},
+		},
+	}
+	rwxPVC := setupWorkspacePVC(codewindInstance)
+	rwxPVC.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+	rwoPVC := setupWorkspacePVC(codewindInstance)
+	rwoPVC.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		want    *WorkspaceVolume
+		wantErr bool
+	}{
+		{
+			name:    fmt.Sprintf("ReadWriteMany workspace PVC is shared"),
+			objects: []runtime.Object{workspacePod, rwxPVC},
+			want:    &WorkspaceVolume{ClaimName: "claim-che-workspace", SubPath: codewindInstance.WorkspaceID + "/projects"},
+		},
+		{
+			name:    fmt.Sprintf("ReadWriteOnce workspace PVC isn't shared"),
+			objects: []runtime.Object{workspacePod, rwoPVC},
+		},
+		{
+			name:    fmt.Sprintf("Workspace pod not found"),
+			objects: []runtime.Object{rwxPVC},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.objects...)
+			volume, err := ResolveWorkspaceVolume(clientset, "default", codewindInstance.WorkspaceID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveWorkspaceVolume returned error %v, expected error: %v", err, tt.wantErr)
+			}
+			if fmt.Sprint(volume) != fmt.Sprint(tt.want) {
+				t.Errorf("ResolveWorkspaceVolume returned %v, expected %v", volume, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeployCodewindSharingWorkspaceVolume(t *testing.T) {
+	codewindInstance := setupCodewind()
+	codewindInstance.WorkspaceVolume = &WorkspaceVolume{ClaimName: "claim-che-workspace", SubPath: codewindInstance.WorkspaceID + "/projects"}
+	clientset := fake.NewSimpleClientset(setupWorkspacePVC(codewindInstance))
+
+	err := DeployCodewind(clientset, codewindInstance, codewindInstance.Namespace, nil)
+	if err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+	if _, err := clientset.CoreV1().PersistentVolumeClaims(codewindInstance.Namespace).Get(codewindInstance.PVCName, metav1.GetOptions{}); err == nil {
+		t.Errorf("PFE PVC was created, expected the workspace PVC to be shared")
+	}
+
+	deploy, _ := clientset.AppsV1().Deployments(codewindInstance.Namespace).Get(constants.PFEPrefix+"-"+codewindInstance.WorkspaceID, metav1.GetOptions{})
+	claimName := deploy.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName
+	subPath := deploy.Spec.Template.Spec.Containers[0].VolumeMounts[0].SubPath
+	if claimName != "claim-che-workspace" || subPath != codewindInstance.WorkspaceID+"/projects" {
+		t.Errorf("PFE mounts %s at subpath %s, expected the workspace PVC at subpath %s", claimName, subPath, codewindInstance.WorkspaceID+"/projects")
+	}
+	for _, env := range deploy.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == "PVC_NAME" && env.Value != claimName {
+			t.Errorf("PFE env var PVC_NAME is %s, expected the mounted PVC %s", env.Value, claimName)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package codewind
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"deploy-pfe/pkg/constants"
+
+	log "github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// waitPollInterval is how often the deployments are checked while waiting for Codewind to become available
+const waitPollInterval = 5 * time.Second
+
+// podWaitingReasons are the container waiting reasons that indicate that a pod is failing, rather than just starting up
+var podWaitingReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// WaitForCodewind waits until the PFE and Performance dashboard deployments of the given workspace are available,
+// logging the reasons why their pods aren't running (such as ImagePullBackOff or Unschedulable) as they come up.
+// An error listing the last known problems is returned if Codewind isn't available before the timeout.
+func WaitForCodewind(clientset kubernetes.Interface, namespace string, workspaceID string, timeout time.Duration) error {
+	deployNames := []string{
+		constants.PFEPrefix + "-" + workspaceID,
+		constants.PerformancePrefix + "-" + workspaceID,
+	}
+
+	log.Infof("Waiting up to %v for Codewind to become available...\n", timeout)
+	problems := map[string]bool{}
+	err := wait.PollImmediate(waitPollInterval, timeout, func() (bool, error) {
+		available := true
+		current := map[string]bool{}
+		for _, name := range deployNames {
+			deploy, err := clientset.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				current["deployment "+name+" not found"] = true
+				available = false
+				continue
+			} else if err != nil {
+				return false, err
+			}
+			if deploymentAvailable(*deploy) {
+				continue
+			}
+			available = false
+
+			pods, err := clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{
+				LabelSelector: labels.SelectorFromSet(deploy.Spec.Selector.MatchLabels).String(),
+			})
+			if err != nil {
+				return false, err
+			}
+			for _, pod := range pods.Items {
+				for _, problem := range podProblems(pod) {
+					current[problem] = true
+				}
+			}
+		}
+
+		// Only log problems the first time they're seen, rather than on every poll
+		for problem := range current {
+			if !problems[problem] {
+				log.Warnln(problem)
+			}
+		}
+		problems = current
+		return available, nil
+	})
+
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("Codewind was not available after %v: %s", timeout, sortedProblems(problems))
+	} else if err != nil {
+		return err
+	}
+	log.Infoln("Codewind is available")
+	return nil
+}
+
+// deploymentAvailable returns true if the latest revision of the deployment has been rolled out, and all of its
+// replicas are available
+func deploymentAvailable(deploy appsv1.Deployment) bool {
+	if deploy.Status.ObservedGeneration < deploy.Generation {
+		return false
+	}
+	replicas := int32(1)
+	if deploy.Spec.Replicas != nil {
+		replicas = *deploy.Spec.Replicas
+	}
+	if deploy.Status.UpdatedReplicas < replicas || deploy.Status.AvailableReplicas < replicas {
+		return false
+	}
+	for _, condition := range deploy.Status.Conditions {
+		if condition.Type == appsv1.DeploymentAvailable {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podProblems returns a description of each reason why the given pod can't run, such as an image that can't
+// be pulled, a crashing container, or no node it can be scheduled on
+func podProblems(pod corev1.Pod) []string {
+	problems := []string{}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse && condition.Reason == corev1.PodReasonUnschedulable {
+			problems = append(problems, fmt.Sprintf("pod %s is %s: %s", pod.GetName(), condition.Reason, condition.Message))
+		}
+	}
+	statuses := []corev1.ContainerStatus{}
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.State.Waiting != nil && podWaitingReasons[status.State.Waiting.Reason] {
+			problems = append(problems, fmt.Sprintf("container %s in pod %s is in %s: %s", status.Name, pod.GetName(), status.State.Waiting.Reason, status.State.Waiting.Message))
+		}
+	}
+	return problems
+}
+
+// sortedProblems joins the given set of problems into a single, stable, message
+func sortedProblems(problems map[string]bool) string {
+	if len(problems) == 0 {
+		return "deployments are still rolling out"
+	}
+	list := []string{}
+	for problem := range problems {
+		list = append(list, problem)
+	}
+	sort.Strings(list)
+	return strings.Join(list, "; ")
+}
@@ -0,0 +1,126 @@
+package codewind
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestDeploymentAvailable(t *testing.T) {
+	available := createPFEDeploy(setupCodewind())
+	available.Generation = 2
+	available.Status = appsv1.DeploymentStatus{
+		ObservedGeneration: 2,
+		UpdatedReplicas:    1,
+		AvailableReplicas:  1,
+		Conditions: []appsv1.DeploymentCondition{
+			{
+				Type:   appsv1.DeploymentAvailable,
+				Status: corev1.ConditionTrue,
+			},
+		},
+	}
+
+	rollingOut := *available.DeepCopy()
+	rollingOut.Generation = 3
+
+	unavailable := *available.DeepCopy()
+	unavailable.Status.AvailableReplicas = 0
+	unavailable.Status.Conditions[0].Status = corev1.ConditionFalse
+
+	tests := []struct {
+		name      string
+		deploy    appsv1.Deployment
+		available bool
+	}{
+		{
+			name:      fmt.Sprintf("Rolled out deployment is available"),
+			deploy:    available,
+			available: true,
+		},
+		{
+			name:      fmt.Sprintf("Deployment with an unobserved generation is not available"),
+			deploy:    rollingOut,
+			available: false,
+		},
+		{
+			name:      fmt.Sprintf("Deployment without available replicas is not available"),
+			deploy:    unavailable,
+			available: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if deploymentAvailable(tt.deploy) != tt.available {
+				t.Errorf("deploymentAvailable returned %v, expected %v", !tt.available, tt.available)
+			}
+		})
+	}
+}
+
+func TestPodProblems(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   corev1.PodStatus
+		problems []string
+	}{
+		{
+			name: fmt.Sprintf("Image pull failure is reported"),
+			status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: "codewind",
+						State: corev1.ContainerState{
+							Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image"},
+						},
+					},
+				},
+			},
+			problems: []string{"ImagePullBackOff"},
+		},
+		{
+			name: fmt.Sprintf("Unschedulable pod is reported"),
+			status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{
+					{
+						Type:    corev1.PodScheduled,
+						Status:  corev1.ConditionFalse,
+						Reason:  corev1.PodReasonUnschedulable,
+						Message: "0/3 nodes are available: 3 Insufficient memory.",
+					},
+				},
+			},
+			problems: []string{"Unschedulable"},
+		},
+		{
+			name: fmt.Sprintf("Container that is still being created is not reported"),
+			status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: "codewind",
+						State: corev1.ContainerState{
+							Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"},
+						},
+					},
+				},
+			},
+			problems: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := podProblems(corev1.Pod{Status: tt.status})
+			if len(problems) != len(tt.problems) {
+				t.Fatalf("podProblems returned %v, expected %v problems", problems, len(tt.problems))
+			}
+			for i, reason := range tt.problems {
+				if !strings.Contains(problems[i], reason) {
+					t.Errorf("Problem %q doesn't mention %v", problems[i], reason)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,432 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"deploy-pfe/pkg/constants"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Source is where the value of a setting was taken from. Flags take precedence over the environment, which takes
+// precedence over the config file, which takes precedence over the defaults
+type Source string
+
+const (
+	// SourceDefault is a setting that wasn't set, and has its default value
+	SourceDefault Source = "default"
+
+	// SourceFile is a setting read from the config file
+	SourceFile Source = "file"
+
+	// SourceEnv is a setting read from its environment variable
+	SourceEnv Source = "env"
+
+	// SourceFlag is a setting read from its command-line flag
+	SourceFlag Source = "flag"
+)
+
+// Config holds every setting of deploy-pfe. Each setting has a key in the config file (such as pfe.image), an
+// environment variable (such as $PFE_IMAGE) and a command-line flag (such as --pfe-image)
+type Config struct {
+	Workspace        WorkspaceConfig
+	PFE              ContainerConfig
+	Performance      ContainerConfig
+	ImagePullPolicy  string
+	PullSecret       PullSecretConfig
+	Volume           VolumeConfig
+	Ownership        string
+	ClusterRole      string
+	Exposure         string
+	HostnameTemplate string
+	Ingress          IngressConfig
+	Route            RouteConfig
+	Gateway          GatewayConfig
+
+	// File is the config file that was loaded, if any
+	File    string
+	sources map[string]Source
+}
+
+// WorkspaceConfig holds the settings of the Che workspace that Codewind is deployed for
+type WorkspaceConfig struct {
+	ID     string
+	CheAPI string
+}
+
+// ContainerConfig holds the settings of a Codewind container
+type ContainerConfig struct {
+	Image           string
+	Tag             string
+	SecurityProfile string
+	Probe           ProbeConfig
+	Resources       ResourcesConfig
+}
+
+// ProbeConfig holds the settings of the readiness and liveness probes of a Codewind container
+type ProbeConfig struct {
+	Path             string
+	ReadinessDelay   int32
+	LivenessDelay    int32
+	Period           int32
+	Timeout          int32
+	FailureThreshold int32
+}
+
+// ResourcesConfig holds the CPU and memory requests and limits of a Codewind container, as Kubernetes quantities
+type ResourcesConfig struct {
+	CPURequest    string
+	MemoryRequest string
+	CPULimit      string
+	MemoryLimit   string
+}
+
+// PullSecretConfig holds the settings of the image pull secret of the Codewind pods
+type PullSecretConfig struct {
+	Name                string
+	PatchServiceAccount bool
+}
+
+// VolumeConfig holds the settings of the Codewind volume
+type VolumeConfig struct {
+	Size                 string
+	StorageClass         string
+	BindTimeout          time.Duration
+	ShareWorkspaceVolume bool
+}
+
+// IngressConfig holds the settings of the Codewind ingress
+type IngressConfig struct {
+	Class       string
+	TLSSecret   string
+	Profile     string
+	Annotations map[string]string
+}
+
+// RouteConfig holds the settings of the Codewind route on OpenShift
+type RouteConfig struct {
+	Termination string
+	TLSSecret   string
+	ShardLabels string
+	Annotations map[string]string
+}
+
+// GatewayConfig holds the settings of the Gateway that Codewind is attached to
+type GatewayConfig struct {
+	Name      string
+	Namespace string
+	Listener  string
+	RouteKind string
+}
+
+// setting is a single setting of the configuration, and the names it's set with
+type setting struct {
+	key   string
+	env   string
+	flag  string
+	usage string
+	// unset describes what's used when the setting is left empty, for settings without a default value
+	unset string
+	value value
+}
+
+// Default returns the configuration that deploy-pfe uses when nothing is set, with the defaults defined in
+// constants/default.go
+func Default() *Config {
+	return &Config{
+		PFE: ContainerConfig{
+			Image:           constants.PFEImage,
+			Tag:             constants.PFEImageTag,
+			SecurityProfile: constants.PFESecurityProfile,
+			Probe: ProbeConfig{
+				Path:             constants.PFEProbePath,
+				ReadinessDelay:   constants.PFEReadinessDelay,
+				LivenessDelay:    constants.PFELivenessDelay,
+				Period:           constants.ProbePeriod,
+				Timeout:          constants.ProbeTimeout,
+				FailureThreshold: constants.ProbeFailureThreshold,
+			},
+			Resources: ResourcesConfig{
+				CPURequest:    constants.PFECPURequest,
+				MemoryRequest: constants.PFEMemoryRequest,
+				CPULimit:      constants.PFECPULimit,
+				MemoryLimit:   constants.PFEMemoryLimit,
+			},
+		},
+		Performance: ContainerConfig{
+			Image:           constants.PerformanceImage,
+			Tag:             constants.PerformanceTag,
+			SecurityProfile: constants.PerformanceSecurityProfile,
+			Probe: ProbeConfig{
+				Path:             constants.PerformanceProbePath,
+				ReadinessDelay:   constants.PerformanceReadinessDelay,
+				LivenessDelay:    constants.PerformanceLivenessDelay,
+				Period:           constants.ProbePeriod,
+				Timeout:          constants.ProbeTimeout,
+				FailureThreshold: constants.ProbeFailureThreshold,
+			},
+			Resources: ResourcesConfig{
+				CPURequest:    constants.PerformanceCPURequest,
+				MemoryRequest: constants.PerformanceMemoryRequest,
+				CPULimit:      constants.PerformanceCPULimit,
+				MemoryLimit:   constants.PerformanceMemoryLimit,
+			},
+		},
+		ImagePullPolicy: string(constants.ImagePullPolicy),
+		Volume: VolumeConfig{
+			Size:        constants.PFEVolumeSize,
+			BindTimeout: constants.PVCBindTimeout,
+		},
+		Ownership:        constants.OwnershipStrategy,
+		HostnameTemplate: constants.HostnameTemplate,
+		Route: RouteConfig{
+			Termination: constants.RouteTermination,
+		},
+		Gateway: GatewayConfig{
+			RouteKind: constants.GatewayRouteKind,
+		},
+		sources: map[string]Source{},
+	}
+}
+
+// settings returns every setting of the configuration, in the order they're printed in
+func (c *Config) settings() []setting {
+	settings := []setting{
+		{key: "workspace.id", env: "CHE_WORKSPACE_ID", flag: "workspace-id", usage: "ID of the Che workspace that Codewind is deployed for", unset: "set by Che", value: stringValue{&c.Workspace.ID}},
+		{key: "workspace.cheAPI", env: "CHE_API", flag: "che-api", usage: "URL of the Che API, used to determine the Che ingress domain", unset: "set by Che", value: stringValue{&c.Workspace.CheAPI}},
+	}
+	settings = append(settings, containerSettings("pfe", "PFE_", &c.PFE, "Codewind PFE")...)
+	settings = append(settings, containerSettings("performance", "PERFORMANCE_", &c.Performance, "Performance dashboard")...)
+	return append(settings, []setting{
+		{key: "imagePullPolicy", env: "CODEWIND_IMAGE_PULL_POLICY", flag: "image-pull-policy", usage: "pull policy of the Codewind containers: Always, IfNotPresent or Never", value: stringValue{&c.ImagePullPolicy}},
+		{key: "pullSecret.name", env: "PFE_PULL_SECRET", flag: "pull-secret", usage: "image pull secret of the Codewind pods", unset: "the workspace registry secret, if it exists", value: stringValue{&c.PullSecret.Name}},
+		{key: "pullSecret.patchServiceAccount", env: "PFE_PATCH_SERVICE_ACCOUNT", flag: "patch-service-account", usage: "also add the image pull secret to the workspace service account", value: boolValue{&c.PullSecret.PatchServiceAccount}},
+		{key: "volume.size", env: "PFE_VOLUME_SIZE", flag: "volume-size", usage: "size of the Codewind volume", value: quantityValue{&c.Volume.Size}},
+		{key: "volume.storageClass", env: "PFE_STORAGE_CLASS", flag: "storage-class", usage: "storage class of the Codewind volume, which must support ReadWriteMany", unset: "detected", value: stringValue{&c.Volume.StorageClass}},
+		{key: "volume.bindTimeout", env: "PFE_PVC_BIND_TIMEOUT", flag: "pvc-bind-timeout", usage: "how long to wait for the Codewind volume to be bound, 0 to not wait", value: durationValue{&c.Volume.BindTimeout}},
+		{key: "volume.shareWorkspaceVolume", env: "PFE_SHARE_WORKSPACE_VOLUME", flag: "share-workspace-volume", usage: "mount the Che workspace volume in Codewind instead of a separate volume", value: boolValue{&c.Volume.ShareWorkspaceVolume}},
+		{key: "ownership", env: "CODEWIND_OWNERSHIP", flag: "ownership", usage: "object that owns the Codewind resources: deployment, pvc or configmap", value: stringValue{&c.Ownership}},
+		{key: "clusterRole", env: "CODEWIND_CLUSTER_ROLE", flag: "cluster-role", usage: "cluster role manifest (setup/install_che/codewind-clusterrole.yaml) that missing permissions are matched against", unset: "read from the cluster", value: stringValue{&c.ClusterRole}},
+		{key: "exposure", env: "CODEWIND_EXPOSURE", flag: "exposure", usage: "how Codewind is exposed: route, ingress, gateway, none, nodeport or loadbalancer", unset: "route on OpenShift, ingress elsewhere", value: stringValue{&c.Exposure}},
+		{key: "hostnameTemplate", env: "CODEWIND_HOSTNAME_TEMPLATE", flag: "hostname-template", usage: "Go template of the hostname that Codewind is exposed on", value: stringValue{&c.HostnameTemplate}},
+		{key: "ingress.class", env: "INGRESS_CLASS", flag: "ingress-class", usage: "ingress class of the Codewind ingress", unset: "cluster default", value: stringValue{&c.Ingress.Class}},
+		{key: "ingress.tlsSecret", env: "INGRESS_TLS_SECRET", flag: "ingress-tls-secret", usage: "secret holding the TLS certificate of the Codewind ingress", unset: "no TLS", value: stringValue{&c.Ingress.TLSSecret}},
+		{key: "ingress.profile", env: "INGRESS_PROFILE", flag: "ingress-profile", usage: "ingress controller the Codewind ingress is annotated for: nginx, traefik, haproxy, gce or custom", unset: "detected", value: stringValue{&c.Ingress.Profile}},
+		{key: "ingress.annotations", env: "INGRESS_ANNOTATIONS", flag: "ingress-annotations", usage: "extra annotations of the Codewind ingress, as a JSON object", unset: "none", value: mapValue{&c.Ingress.Annotations}},
+		{key: "route.termination", env: "ROUTE_TERMINATION", flag: "route-termination", usage: "how TLS is terminated by the Codewind route: passthrough, edge or reencrypt", value: stringValue{&c.Route.Termination}},
+		{key: "route.tlsSecret", env: "ROUTE_TLS_SECRET", flag: "route-tls-secret", usage: "secret holding the certificates of the Codewind route", unset: "none", value: stringValue{&c.Route.TLSSecret}},
+		{key: "route.shardLabels", env: "ROUTE_SHARD_LABELS", flag: "route-shard-labels", usage: "labels selecting the router shard that exposes the Codewind route, such as router=internal", unset: "none", value: stringValue{&c.Route.ShardLabels}},
+		{key: "route.annotations", env: "ROUTE_ANNOTATIONS", flag: "route-annotations", usage: "extra annotations of the Codewind route, as a JSON object", unset: "none", value: mapValue{&c.Route.Annotations}},
+		{key: "gateway.name", env: "GATEWAY_NAME", flag: "gateway-name", usage: "Gateway that Codewind is attached to with the gateway exposure", unset: "none", value: stringValue{&c.Gateway.Name}},
+		{key: "gateway.namespace", env: "GATEWAY_NAMESPACE", flag: "gateway-namespace", usage: "namespace of the Gateway that Codewind is attached to", unset: "Codewind's namespace", value: stringValue{&c.Gateway.Namespace}},
+		{key: "gateway.listener", env: "GATEWAY_LISTENER", flag: "gateway-listener", usage: "listener of the Gateway that Codewind is attached to", unset: "all listeners", value: stringValue{&c.Gateway.Listener}},
+		{key: "gateway.routeKind", env: "GATEWAY_ROUTE_KIND", flag: "gateway-route-kind", usage: "kind of the Gateway API route of Codewind: TLSRoute or HTTPRoute", value: stringValue{&c.Gateway.RouteKind}},
+	}...)
+}
+
+// containerSettings returns the settings of a Codewind container, with the given config file key, environment
+// variable prefix and description
+func containerSettings(key string, prefix string, container *ContainerConfig, name string) []setting {
+	flagPrefix := strings.ToLower(strings.TrimSuffix(prefix, "_")) + "-"
+	return []setting{
+		{key: key + ".image", env: prefix + "IMAGE", flag: flagPrefix + "image", usage: "image of the " + name + " container", value: stringValue{&container.Image}},
+		{key: key + ".tag", env: prefix + "TAG", flag: flagPrefix + "tag", usage: "image tag of the " + name + " container", value: stringValue{&container.Tag}},
+		{key: key + ".securityProfile", env: prefix + "SECURITY_PROFILE", flag: flagPrefix + "security-profile", usage: "security profile of the " + name + " container", value: stringValue{&container.SecurityProfile}},
+		{key: key + ".probe.path", env: prefix + "PROBE_PATH", flag: flagPrefix + "probe-path", usage: "path probed by the readiness and liveness probes of the " + name + " container", value: stringValue{&container.Probe.Path}},
+		{key: key + ".probe.readinessDelay", env: prefix + "READINESS_DELAY", flag: flagPrefix + "readiness-delay", usage: "seconds before the readiness of the " + name + " container is first probed", value: int32Value{&container.Probe.ReadinessDelay}},
+		{key: key + ".probe.livenessDelay", env: prefix + "LIVENESS_DELAY", flag: flagPrefix + "liveness-delay", usage: "seconds before the liveness of the " + name + " container is first probed", value: int32Value{&container.Probe.LivenessDelay}},
+		{key: key + ".probe.period", env: prefix + "PROBE_PERIOD", flag: flagPrefix + "probe-period", usage: "seconds between two probes of the " + name + " container", value: int32Value{&container.Probe.Period}},
+		{key: key + ".probe.timeout", env: prefix + "PROBE_TIMEOUT", flag: flagPrefix + "probe-timeout", usage: "seconds after which a probe of the " + name + " container times out", value: int32Value{&container.Probe.Timeout}},
+		{key: key + ".probe.failureThreshold", env: prefix + "PROBE_FAILURE_THRESHOLD", flag: flagPrefix + "probe-failure-threshold", usage: "failed probes before the " + name + " container is marked unready, or restarted", value: int32Value{&container.Probe.FailureThreshold}},
+		{key: key + ".resources.cpuRequest", env: prefix + "CPU_REQUEST", flag: flagPrefix + "cpu-request", usage: "CPU requested for the " + name + " container", value: quantityValue{&container.Resources.CPURequest}},
+		{key: key + ".resources.memoryRequest", env: prefix + "MEMORY_REQUEST", flag: flagPrefix + "memory-request", usage: "memory requested for the " + name + " container", value: quantityValue{&container.Resources.MemoryRequest}},
+		{key: key + ".resources.cpuLimit", env: prefix + "CPU_LIMIT", flag: flagPrefix + "cpu-limit", usage: "maximum CPU the " + name + " container can use", value: quantityValue{&container.Resources.CPULimit}},
+		{key: key + ".resources.memoryLimit", env: prefix + "MEMORY_LIMIT", flag: flagPrefix + "memory-limit", usage: "maximum memory the " + name + " container can use", value: quantityValue{&container.Resources.MemoryLimit}},
+	}
+}
+
+// Loader loads the configuration from the config file, the environment and the flags of a command
+type Loader struct {
+	path  string
+	flags map[string]string
+}
+
+// flagValue records the value of a setting's flag, which is only parsed when the configuration is loaded so that
+// flags take precedence over the config file and the environment
+type flagValue struct {
+	key    string
+	flags  map[string]string
+	isBool bool
+}
+
+func (v flagValue) Set(s string) error {
+	v.flags[v.key] = s
+	return nil
+}
+
+func (v flagValue) String() string { return "" }
+
+func (v flagValue) IsBoolFlag() bool { return v.isBool }
+
+// AddFlags adds the --config flag and a flag for every setting to the flag set of a command, returning the Loader
+// that loads the configuration once the flags have been parsed
+func AddFlags(flags *flag.FlagSet) *Loader {
+	loader := &Loader{flags: map[string]string{}}
+	flags.StringVar(&loader.path, "config", "", "YAML config file of deploy-pfe ($DEPLOY_PFE_CONFIG)")
+	for _, setting := range Default().settings() {
+		_, isBool := setting.value.(boolValue)
+		flags.Var(flagValue{key: setting.key, flags: loader.flags, isBool: isBool}, setting.flag, setting.usage+" ($"+setting.env+")")
+	}
+	return loader
+}
+
+// Load returns the configuration from the defaults, the config file set with --config or $DEPLOY_PFE_CONFIG, the
+// environment and the flags, in increasing order of precedence. Each setting is checked to be of the right type
+func (l *Loader) Load() (*Config, error) {
+	path := l.path
+	if path == "" {
+		path = os.Getenv("DEPLOY_PFE_CONFIG")
+	}
+	return load(path, l.flags)
+}
+
+// load returns the configuration from the defaults, the given config file, the environment and the given flag values
+func load(path string, flags map[string]string) (*Config, error) {
+	config := Default()
+	settings := map[string]setting{}
+	for _, setting := range config.settings() {
+		settings[setting.key] = setting
+	}
+
+	if path != "" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read config file: %v", err)
+		}
+		values := map[string]interface{}{}
+		err = yaml.Unmarshal(content, &values)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse config file %s: %v", path, err)
+		}
+		err = config.loadFile(path, settings, "", values)
+		if err != nil {
+			return nil, err
+		}
+		config.File = path
+	}
+
+	for _, setting := range config.settings() {
+		if value := os.Getenv(setting.env); value != "" {
+			err := setting.value.Set(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for $%s, %v", value, setting.env, err)
+			}
+			config.sources[setting.key] = SourceEnv
+		}
+	}
+
+	for _, setting := range config.settings() {
+		if value, ok := flags[setting.key]; ok {
+			err := setting.value.Set(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for --%s, %v", value, setting.flag, err)
+			}
+			config.sources[setting.key] = SourceFlag
+		}
+	}
+	return config, nil
+}
+
+// loadFile sets the settings found in the given section of a config file, which nests settings by the parts of their key
+func (c *Config) loadFile(path string, settings map[string]setting, prefix string, values map[string]interface{}) error {
+	for name, value := range values {
+		key := prefix + name
+		setting, ok := settings[key]
+		if !ok {
+			section, isSection := value.(map[string]interface{})
+			if !isSection {
+				return fmt.Errorf("unknown setting %s in config file %s", key, path)
+			}
+			err := c.loadFile(path, settings, key+".", section)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if value == nil {
+			continue
+		}
+
+		var s string
+		switch v := value.(type) {
+		case string:
+			s = v
+		case bool:
+			s = strconv.FormatBool(v)
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		case map[string]interface{}:
+			// Annotations are a map in the config file, rather than the JSON object they are in the environment
+			annotations, isMap := setting.value.(mapValue)
+			if !isMap {
+				return fmt.Errorf("invalid value for %s in config file %s, expected a single value", key, path)
+			}
+			m := map[string]string{}
+			for annotation, annotationValue := range v {
+				m[annotation] = fmt.Sprint(annotationValue)
+			}
+			*annotations.value = m
+			c.sources[key] = SourceFile
+			continue
+		default:
+			return fmt.Errorf("invalid value for %s in config file %s, expected a single value", key, path)
+		}
+		err := setting.value.Set(s)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for %s in config file %s, %v", s, key, path, err)
+		}
+		c.sources[key] = SourceFile
+	}
+	return nil
+}
+
+// Source returns where the setting with the given config file key was taken from
+func (c *Config) Source(key string) Source {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// WriteTable writes a table of every setting to out, with its effective value and where it was taken from
+func (c *Config) WriteTable(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+	for _, setting := range c.settings() {
+		value := setting.value.String()
+		if value == "" && setting.unset != "" {
+			value = "(" + setting.unset + ")"
+		}
+		var source string
+		switch c.Source(setting.key) {
+		case SourceFile:
+			source = "file " + c.File
+		case SourceEnv:
+			source = "env $" + setting.env
+		case SourceFlag:
+			source = "flag --" + setting.flag
+		default:
+			source = string(SourceDefault)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", setting.key, value, source)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"bytes"
+	"deploy-pfe/pkg/constants"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes a config file with the given content, returning its path
+func writeConfigFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "deploy-pfe-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	_, err = file.WriteString(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file.Name()
+}
+
+// TestLoad verifies that flags take precedence over the environment, which takes precedence over the config file,
+// which takes precedence over the defaults, and that the source of every setting is recorded
+func TestLoad(t *testing.T) {
+	path := writeConfigFile(t, `
+pfe:
+  image: registry.example.com/codewind-pfe
+  tag: "0.9"
+  probe:
+    readinessDelay: 20
+volume:
+  size: 10Gi
+  shareWorkspaceVolume: true
+ingress:
+  annotations:
+    example.com/timeout: 1h
+`)
+	defer os.Remove(path)
+	os.Setenv("PFE_TAG", "0.10")
+	defer os.Unsetenv("PFE_TAG")
+	os.Setenv("PFE_VOLUME_SIZE", "20Gi")
+	defer os.Unsetenv("PFE_VOLUME_SIZE")
+
+	config, err := load(path, map[string]string{"volume.size": "30Gi", "volume.bindTimeout": "5m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		value  interface{}
+		actual interface{}
+		source Source
+	}{
+		{
+			name:   fmt.Sprintf("Default a setting that wasn't set"),
+			key:    "pfe.probe.path",
+			value:  constants.PFEProbePath,
+			actual: config.PFE.Probe.Path,
+			source: SourceDefault,
+		},
+		{
+			name:   fmt.Sprintf("Read a setting from the config file"),
+			key:    "pfe.image",
+			value:  "registry.example.com/codewind-pfe",
+			actual: config.PFE.Image,
+			source: SourceFile,
+		},
+		{
+			name:   fmt.Sprintf("Read a number from the config file"),
+			key:    "pfe.probe.readinessDelay",
+			value:  int32(20),
+			actual: config.PFE.Probe.ReadinessDelay,
+			source: SourceFile,
+		},
+		{
+			name:   fmt.Sprintf("Read a bool from the config file"),
+			key:    "volume.shareWorkspaceVolume",
+			value:  true,
+			actual: config.Volume.ShareWorkspaceVolume,
+			source: SourceFile,
+		},
+		{
+			name:   fmt.Sprintf("Read annotations from a map in the config file"),
+			key:    "ingress.annotations",
+			value:  "1h",
+			actual: config.Ingress.Annotations["example.com/timeout"],
+			source: SourceFile,
+		},
+		{
+			name:   fmt.Sprintf("Override the config file with the environment"),
+			key:    "pfe.tag",
+			value:  "0.10",
+			actual: config.PFE.Tag,
+			source: SourceEnv,
+		},
+		{
+			name:   fmt.Sprintf("Override the config file and environment with a flag"),
+			key:    "volume.size",
+			value:  "30Gi",
+			actual: config.Volume.Size,
+			source: SourceFlag,
+		},
+		{
+			name:   fmt.Sprintf("Override a default with a flag"),
+			key:    "volume.bindTimeout",
+			value:  5 * time.Minute,
+			actual: config.Volume.BindTimeout,
+			source: SourceFlag,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.actual != tt.value {
+				t.Errorf("Setting %s is %v, expected %v", tt.key, tt.actual, tt.value)
+			}
+			if source := config.Source(tt.key); source != tt.source {
+				t.Errorf("Setting %s was taken from %s, expected %s", tt.key, source, tt.source)
+			}
+		})
+	}
+}
+
+// TestLoadInvalid verifies that settings of the wrong type and unknown settings are rejected, naming where they were set
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		flags   map[string]string
+		message string
+	}{
+		{
+			name:    fmt.Sprintf("Reject an unknown setting in the config file"),
+			file:    "pfe:\n  imag: codewind-pfe\n",
+			message: "unknown setting pfe.imag",
+		},
+		{
+			name:    fmt.Sprintf("Reject a list in the config file"),
+			file:    "pfe:\n  probe:\n    readinessDelay: [10]\n",
+			message: "pfe.probe.readinessDelay",
+		},
+		{
+			name:    fmt.Sprintf("Reject a quantity that isn't positive in the config file"),
+			file:    "volume:\n  size: -5Gi\n",
+			message: "volume.size",
+		},
+		{
+			name:    fmt.Sprintf("Reject a number that isn't whole in the environment"),
+			env:     map[string]string{"PERFORMANCE_PROBE_PERIOD": "1.5"},
+			message: "$PERFORMANCE_PROBE_PERIOD",
+		},
+		{
+			name:    fmt.Sprintf("Reject annotations that aren't a JSON object in the environment"),
+			env:     map[string]string{"ROUTE_ANNOTATIONS": "timeout=1h"},
+			message: "$ROUTE_ANNOTATIONS",
+		},
+		{
+			name:    fmt.Sprintf("Reject a bool flag that isn't true or false"),
+			flags:   map[string]string{"pullSecret.patchServiceAccount": "yes please"},
+			message: "--patch-service-account",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			if tt.file != "" {
+				path = writeConfigFile(t, tt.file)
+				defer os.Remove(path)
+			}
+			for name, value := range tt.env {
+				os.Setenv(name, value)
+				defer os.Unsetenv(name)
+			}
+
+			_, err := load(path, tt.flags)
+			if err == nil {
+				t.Fatalf("Invalid settings weren't rejected")
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Error %q doesn't mention %s", err, tt.message)
+			}
+		})
+	}
+}
+
+// TestWriteTable verifies that the effective config is printed with the source of every setting
+func TestWriteTable(t *testing.T) {
+	os.Setenv("CHE_WORKSPACE_ID", "workspace1erok6723m74axkg")
+	defer os.Unsetenv("CHE_WORKSPACE_ID")
+	config, err := load("", map[string]string{"pfe.tag": "0.9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = config.WriteTable(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(config.settings())+1 {
+		t.Fatalf("Printed %d lines, expected a header and %d settings", len(lines), len(config.settings()))
+	}
+
+	expected := map[string][]string{
+		"workspace.id":        {"workspace1erok6723m74axkg", "env $CHE_WORKSPACE_ID"},
+		"pfe.tag":             {"0.9", "flag --pfe-tag"},
+		"volume.size":         {constants.PFEVolumeSize, "default"},
+		"volume.storageClass": {"(detected)", "default"},
+	}
+	for _, line := range lines[1:] {
+		fields := strings.SplitN(line, " ", 2)
+		want, ok := expected[fields[0]]
+		if !ok {
+			continue
+		}
+		delete(expected, fields[0])
+		for _, field := range want {
+			if !strings.Contains(line, field) {
+				t.Errorf("Setting %s is printed as %q, expected it to contain %q", fields[0], line, field)
+			}
+		}
+	}
+	for key := range expected {
+		t.Errorf("Setting %s wasn't printed", key)
+	}
+}
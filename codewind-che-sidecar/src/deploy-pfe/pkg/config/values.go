@@ -0,0 +1,101 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// value is a typed setting of the configuration, parsed from the string form it has in the environment and in flags
+type value interface {
+	// Set parses the given string into the setting, returning what was expected if it's invalid
+	Set(string) error
+	// String returns the setting in the form that Set parses
+	String() string
+}
+
+type stringValue struct{ value *string }
+
+func (v stringValue) Set(s string) error {
+	*v.value = s
+	return nil
+}
+
+func (v stringValue) String() string { return *v.value }
+
+type boolValue struct{ value *bool }
+
+func (v boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("expected true or false")
+	}
+	*v.value = b
+	return nil
+}
+
+func (v boolValue) String() string { return strconv.FormatBool(*v.value) }
+
+type int32Value struct{ value *int32 }
+
+func (v int32Value) Set(s string) error {
+	i, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return fmt.Errorf("expected a whole number")
+	}
+	*v.value = int32(i)
+	return nil
+}
+
+func (v int32Value) String() string { return strconv.FormatInt(int64(*v.value), 10) }
+
+type durationValue struct{ value *time.Duration }
+
+func (v durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("expected a duration such as 90s or 5m")
+	}
+	*v.value = d
+	return nil
+}
+
+func (v durationValue) String() string { return v.value.String() }
+
+// quantityValue is a Kubernetes quantity such as 500m or 1Gi, kept in the form it was set in
+type quantityValue struct{ value *string }
+
+func (v quantityValue) Set(s string) error {
+	quantity, err := resource.ParseQuantity(s)
+	if err != nil || quantity.Sign() <= 0 {
+		return fmt.Errorf("expected a positive quantity such as 500m or 1Gi")
+	}
+	*v.value = s
+	return nil
+}
+
+func (v quantityValue) String() string { return *v.value }
+
+// mapValue is a set of annotations, set as a JSON object in the environment and in flags
+type mapValue struct{ value *map[string]string }
+
+func (v mapValue) Set(s string) error {
+	m := map[string]string{}
+	err := json.Unmarshal([]byte(s), &m)
+	if err != nil {
+		return fmt.Errorf(`expected a JSON object such as {"example.com/annotation": "value"}`)
+	}
+	*v.value = m
+	return nil
+}
+
+func (v mapValue) String() string {
+	if len(*v.value) == 0 {
+		return ""
+	}
+	s, _ := json.Marshal(*v.value)
+	return string(s)
+}
@@ -1,6 +1,10 @@
 package constants
 
-import corev1 "k8s.io/api/core/v1"
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
 
 const (
 	// PFEPrefix is the prefix all PFE-related resources: deployment, service, and ingress/route
@@ -18,7 +22,7 @@ const (
 	// PFEImageTag is the image tag associated with the docker image that's used for Codewind-PFE
 	PFEImageTag = "latest"
 
-	// PFEVolumeSize is the size of the volume to use for PFE
+	// PFEVolumeSize is the default size of the volume to use for PFE
 	PFEVolumeSize = "5Gi"
 
 	// PerformanceTag is the image tag associated with the docker image that's used for the Performance dashboard
@@ -27,12 +31,99 @@ const (
 	// ImagePullPolicy is the pull policy used for all containers in Codewind, defaults to Always
 	ImagePullPolicy = corev1.PullAlways
 
+	// PFESecurityProfile is the security profile of the Codewind-PFE container, privileged so that buildah can run as root
+	PFESecurityProfile = "privileged"
+
+	// PerformanceSecurityProfile is the security profile of the Performance dashboard container
+	PerformanceSecurityProfile = "privileged"
+
+	// NonRootUID is the user that Codewind containers run as with a non-root security profile, outside of OpenShift
+	NonRootUID = 1001
+
 	// PFEContainerPort is the port at which Codewind-PFE is exposed
 	PFEContainerPort = 9191
 
+	// PFEHTTPPort is the port at which Codewind-PFE serves plain HTTP instead, when TLS is terminated in front of it
+	PFEHTTPPort = 9090
+
 	// PerformanceContainerPort is the port at which the Performance dashboard is exposed
 	PerformanceContainerPort = 9095
 
+	// PFEProbePath is the HTTPS path used by the readiness and liveness probes of Codewind-PFE
+	PFEProbePath = "/api/v1/environment"
+
+	// PFEReadinessDelay is the number of seconds after Codewind-PFE starts before its readiness is first probed
+	PFEReadinessDelay = 10
+
+	// PFELivenessDelay is the number of seconds after Codewind-PFE starts before its liveness is first probed
+	PFELivenessDelay = 60
+
+	// PerformanceProbePath is the HTTP path used by the readiness and liveness probes of the Performance dashboard
+	PerformanceProbePath = "/performance/"
+
+	// PerformanceReadinessDelay is the number of seconds after the Performance dashboard starts before its readiness is first probed
+	PerformanceReadinessDelay = 5
+
+	// PerformanceLivenessDelay is the number of seconds after the Performance dashboard starts before its liveness is first probed
+	PerformanceLivenessDelay = 30
+
+	// ProbePeriod is the number of seconds between two probes of a Codewind container
+	ProbePeriod = 15
+
+	// ProbeTimeout is the number of seconds after which a probe of a Codewind container times out
+	ProbeTimeout = 10
+
+	// ProbeFailureThreshold is the number of failed probes after which a Codewind container is marked as unready, or restarted
+	ProbeFailureThreshold = 3
+
+	// PFECPURequest is the amount of CPU requested for the Codewind-PFE container
+	PFECPURequest = "500m"
+
+	// PFEMemoryRequest is the amount of memory requested for the Codewind-PFE container
+	PFEMemoryRequest = "1Gi"
+
+	// PFECPULimit is the maximum amount of CPU the Codewind-PFE container (and the builds it runs) can use
+	PFECPULimit = "2"
+
+	// PFEMemoryLimit is the maximum amount of memory the Codewind-PFE container (and the builds it runs) can use
+	PFEMemoryLimit = "4Gi"
+
+	// PerformanceCPURequest is the amount of CPU requested for the Performance dashboard container
+	PerformanceCPURequest = "100m"
+
+	// PerformanceMemoryRequest is the amount of memory requested for the Performance dashboard container
+	PerformanceMemoryRequest = "128Mi"
+
+	// PerformanceCPULimit is the maximum amount of CPU the Performance dashboard container can use
+	PerformanceCPULimit = "500m"
+
+	// PerformanceMemoryLimit is the maximum amount of memory the Performance dashboard container can use
+	PerformanceMemoryLimit = "512Mi"
+
+	// IngressAPIVersion is the API version used for the Codewind ingress when it hasn't been detected from the cluster
+	IngressAPIVersion = "networking.k8s.io/v1"
+
+	// IngressProfile is the ingress controller that the Codewind ingress is annotated for, when it isn't set or detected from the cluster
+	IngressProfile = "nginx"
+
+	// RouteTermination is how TLS is terminated by the Codewind route on OpenShift: edge, reencrypt or passthrough (to PFE)
+	RouteTermination = "passthrough"
+
+	// GatewayRouteKind is the kind of Gateway API route that Codewind is exposed through: TLSRoute (passthrough to PFE) or HTTPRoute
+	GatewayRouteKind = "TLSRoute"
+
+	// HostnameTemplate is the Go template of the hostname that Codewind is exposed on, within the Che ingress domain
+	HostnameTemplate = "{{.Prefix}}-{{.WorkspaceID}}-{{.CheDomain}}"
+
+	// OwnershipStrategy is the object that owns the Codewind resources by default: the workspace deployment, the workspace pvc, or an anchor configmap
+	OwnershipStrategy = "deployment"
+
+	// PVCBindTimeout is how long deploy-pfe waits for the Codewind PVC to be bound to a volume by default
+	PVCBindTimeout = 2 * time.Minute
+
+	// WaitTimeout is how long `deploy-pfe wait` waits for Codewind to become available by default
+	WaitTimeout = 10 * time.Minute
+
 	// ROKSStorageClass referencces the storage class to use on ROKS (OpenShift on IKS)
 	ROKSStorageClass = "ibmc-file-bronze"
 )
@@ -0,0 +1,21 @@
+package kube
+
+import "fmt"
+
+// NamespaceUnresolvedError is returned when the current namespace can't be determined from the Kubernetes context
+type NamespaceUnresolvedError struct {
+	Err error
+}
+
+func (e *NamespaceUnresolvedError) Error() string {
+	return fmt.Sprintf("namespace unresolved: %v", e.Err)
+}
+
+// DiscoveryFailedError is returned when the API groups available on the cluster can't be discovered
+type DiscoveryFailedError struct {
+	Err error
+}
+
+func (e *DiscoveryFailedError) Error() string {
+	return fmt.Sprintf("API discovery failed: %v", e.Err)
+}
@@ -1,9 +1,7 @@
 package kube
 
 import (
-	"os"
-
-	log "github.com/sirupsen/logrus"
+	"fmt"
 
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
@@ -21,34 +19,58 @@ func GetKubeClientConfig() clientcmd.ClientConfig {
 }
 
 // GetCurrentNamespace gets the current namespace in the Kubernetes context
-func GetCurrentNamespace() string {
+func GetCurrentNamespace() (string, error) {
 	// Instantiate loader for kubeconfig file.
 	kubeconfig := GetKubeClientConfig()
 	namespace, _, err := kubeconfig.Namespace()
 	if err != nil {
-		panic(err)
+		return "", &NamespaceUnresolvedError{Err: err}
 	}
-	return namespace
+	return namespace, nil
 }
 
 // DetectOpenShift determines if we're running on an OpenShift cluster
 // From https://github.com/eclipse/che-operator/blob/2f639261d8b5416b2934591e12925ee0935814dd/pkg/util/util.go#L63
-func DetectOpenShift(config *rest.Config) bool {
+func DetectOpenShift(config *rest.Config) (bool, error) {
 	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
 	if err != nil {
-		log.Errorf("Unable to detect if running on OpenShift: %v\n", err)
-		os.Exit(1)
+		return false, &DiscoveryFailedError{Err: err}
 	}
 	apiList, err := discoveryClient.ServerGroups()
 	if err != nil {
-		log.Errorf("Error attempting to retrieve list of API Groups: %v\n", err)
-		os.Exit(1)
+		return false, &DiscoveryFailedError{Err: err}
 	}
 	apiGroups := apiList.Groups
 	for _, group := range apiGroups {
 		if group.Name == "route.openshift.io" {
-			return true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ingressAPIVersions are the API versions that can serve ingresses, from newest to oldest
+var ingressAPIVersions = []string{"networking.k8s.io/v1", "networking.k8s.io/v1beta1", "extensions/v1beta1"}
+
+// DetectIngressAPIVersion determines the newest ingress API version served by the cluster. Older ingress API versions
+// have been removed from current Kubernetes releases, while networking.k8s.io/v1 isn't available on older ones
+func DetectIngressAPIVersion(discoveryClient discovery.DiscoveryInterface) (string, error) {
+	return DetectAPIVersion(discoveryClient, "ingresses", ingressAPIVersions)
+}
+
+// DetectAPIVersion determines the first of the given API versions that serves the given resource on the cluster
+func DetectAPIVersion(discoveryClient discovery.DiscoveryInterface, resource string, apiVersions []string) (string, error) {
+	for _, apiVersion := range apiVersions {
+		resources, err := discoveryClient.ServerResourcesForGroupVersion(apiVersion)
+		if err != nil || resources == nil {
+			// The API version isn't served by the cluster
+			continue
+		}
+		for _, apiResource := range resources.APIResources {
+			if apiResource.Name == resource {
+				return apiVersion, nil
+			}
 		}
 	}
-	return false
+	return "", &DiscoveryFailedError{Err: fmt.Errorf("none of the API versions %v serve %s on the cluster", apiVersions, resource)}
 }
@@ -320,7 +320,8 @@ if [[ $CLEAN_DEPLOY == "y" ]]; then
 fi
 
 echo -e "${CYAN}> Applying codewind cluster roles${RESET}"
-kubectl apply -f "$CODEWIND_CHE/setup/install_che/codewind-clusterrole.yaml" -n $CHE_NS > /dev/null 2>&1
+# The cluster role binding of the cluster scoped permissions names the namespace of the service account itself
+sed "s/^  namespace: che$/  namespace: $CHE_NS/" "$CODEWIND_CHE/setup/install_che/codewind-clusterrole.yaml" | kubectl apply -f - -n $CHE_NS > /dev/null 2>&1
 displayMsg $? "Failed to apply codewind cluster role." true
 
 echo -e "${CYAN}> Applying tekton cluster roles${RESET}"